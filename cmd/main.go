@@ -12,6 +12,7 @@ import (
 	"quote_book/pkg/config"
 	"quote_book/pkg/db"
 	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/service"
 	"syscall"
 	"time"
 
@@ -32,12 +33,17 @@ func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	srv := new(server)
 
-	db, err := ConfigDB(cfg.Database.Type)
+	db, err := ConfigDB(&cfg.Database)
 	if err != nil {
 		log.Fatalf("DB starting err: %v", err)
 	}
+	defer db.Close()
 
-	srv.api = api.New(db, logger)
+	loc, err := time.LoadLocation(cfg.Daily.TimeZone)
+	if err != nil {
+		log.Fatalf("Daily time zone err: %v", err)
+	}
+	srv.api = api.New(db, logger, service.WithLocation(loc))
 
 	srv.httpServer = configServer(&cfg.Server, srv.api.Router())
 
@@ -70,10 +76,18 @@ func main() {
 	log.Println("Server gracefully stoped")
 }
 
-func ConfigDB(t string) (db.DB, error) {
-	switch t {
+func ConfigDB(cfg *config.DatabaseConfig) (db.DB, error) {
+	switch cfg.Type {
 	case "memdb":
-		return memdb.New(), nil
+		policy, err := memdb.ParseSyncPolicy(cfg.WAL.Sync)
+		if err != nil {
+			return nil, err
+		}
+		return memdb.New(
+			memdb.WithWAL(cfg.WAL.Path),
+			memdb.WithSyncPolicy(policy, time.Duration(cfg.WAL.SyncIntervalMs)*time.Millisecond),
+			memdb.WithSnapshots(cfg.Snapshot.Dir, time.Duration(cfg.Snapshot.IntervalSec)*time.Second),
+		)
 	default:
 		return nil, errors.New("no such db")
 	}
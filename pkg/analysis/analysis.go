@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// терм после анализа; Pos - номер слова в исходном тексте, с пропусками на месте отброшенных слов,
+// чтобы поиск фраз учитывал расстояние между словами
+type Token struct {
+	Term string
+	Pos  int
+}
+
+// шаг конвейера: возвращает измененный терм или false, если терм нужно отбросить
+type Filter func(term string) (string, bool)
+
+type Analyzer struct {
+	filters []Filter
+}
+
+func New(filters ...Filter) *Analyzer {
+	return &Analyzer{filters: filters}
+}
+
+func (a *Analyzer) Analyze(text string) []Token {
+	words := Tokenize(text)
+	tokens := make([]Token, 0, len(words))
+
+	for pos, word := range words {
+		term, keep := word, true
+		for _, filter := range a.filters {
+			if term, keep = filter(term); !keep {
+				break
+			}
+		}
+		if keep && term != "" {
+			tokens = append(tokens, Token{Term: term, Pos: pos})
+		}
+	}
+	return tokens
+}
+
+func (a *Analyzer) Terms(text string) []string {
+	tokens := a.Analyze(text)
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		terms = append(terms, token.Term)
+	}
+	return terms
+}
+
+// строка целиком, пропущенная через фильтры: слова разделяются только пробелами, поэтому пунктуация остается
+// ("А. Эйнштейн"), а крайние и повторные пробелы схлопываются. Для сравнения строк, а не для поиска по словам
+func (a *Analyzer) Normalize(text string) string {
+	words := strings.Fields(text)
+	kept := words[:0]
+	for _, word := range words {
+		term, keep := word, true
+		for _, filter := range a.filters {
+			if term, keep = filter(term); !keep {
+				break
+			}
+		}
+		if keep && term != "" {
+			kept = append(kept, term)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// выбирает анализатор для конкретного текста
+type Selector func(text string) *Analyzer
+
+// слова - последовательности букв и цифр, все остальное - разделители
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func Lowercase(term string) (string, bool) {
+	return strings.ToLower(term), true
+}
+
+// ё и е в текстах пишут вперемешку, поэтому не различаем их
+func FoldYo(term string) (string, bool) {
+	return strings.NewReplacer("ё", "е", "Ё", "Е").Replace(term), true
+}
+
+// ожидает термы в нижнем регистре
+func StopWords(words ...string) Filter {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+
+	return func(term string) (string, bool) {
+		return term, !set[term]
+	}
+}
+
+// стеммер выбирается по алфавиту слова, чтобы английские слова в русской цитате (и наоборот) тоже приводились к основе
+func Stem(term string) (string, bool) {
+	switch scriptOf(term) {
+	case Cyrillic:
+		return StemRussian(term), true
+	case Latin:
+		return StemEnglish(term), true
+	default:
+		return term, true
+	}
+}
+
+var (
+	Russian = New(Lowercase, FoldYo, StopWords(russianStopWords...), Stem)
+	English = New(Lowercase, FoldYo, StopWords(englishStopWords...), Stem)
+
+	// только свертка, без стоп-слов и стемминга: имена авторов и тексты дублей сравниваются целиком,
+	// а "Лев" и "Львы" - разные авторы. Регистр сворачивается полностью, а не через Lowercase,
+	// потому что ключ должен совпадать у всех строк, равных по strings.EqualFold
+	Folding = New(FoldYo, FoldCase)
+)
+
+// анализатор по преобладающему алфавиту текста; без букв - русский, как основная часть коллекции
+func ByScript(text string) *Analyzer {
+	if DetectScript(text) == Latin {
+		return English
+	}
+	return Russian
+}
+
+// простая свертка регистра Unicode: буква заменяется наименьшей из равных ей без учета регистра,
+// поэтому строки, равные по strings.EqualFold, после свертки совпадают
+func FoldCase(term string) (string, bool) {
+	return strings.Map(foldRune, term), true
+}
+
+func foldRune(r rune) rune {
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		folded = min(folded, f)
+	}
+	return folded
+}
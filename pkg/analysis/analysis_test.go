@@ -0,0 +1,141 @@
+package analysis_test
+
+import (
+	"quote_book/pkg/analysis"
+	"reflect"
+	"testing"
+)
+
+func TestStemRussian(t *testing.T) {
+	cases := map[string]string{
+		"важнейшие":  "важн",
+		"важности":   "важност",
+		"важностью":  "важност",
+		"валялась":   "валя",
+		"валяются":   "валя",
+		"валился":    "вал",
+		"вальдшнепа": "вальдшнеп",
+		"важную":     "важн",
+		"вазах":      "ваз",
+		"победить":   "побед",
+		"победы":     "побед",
+		"любовь":     "любв",
+		"любви":      "любв",
+		"любовью":    "любв",
+		"я":          "я",
+	}
+
+	for word, want := range cases {
+		if got := analysis.StemRussian(word); got != want {
+			t.Errorf("StemRussian(%q) = %q; want %q", word, got, want)
+		}
+	}
+}
+
+func TestStemEnglish(t *testing.T) {
+	cases := map[string]string{
+		"consigned":    "consign",
+		"consistently": "consist",
+		"consolation":  "consol",
+		"consolatory":  "consolatori",
+		"conspiracy":   "conspiraci",
+		"constable":    "constabl",
+		"knaves":       "knave",
+		"kneeling":     "kneel",
+		"knitting":     "knit",
+		"knives":       "knive",
+		"generously":   "generous",
+		"happily":      "happili",
+		"hoping":       "hope",
+		"hopping":      "hop",
+		"cries":        "cri",
+		"ties":         "tie",
+		"gas":          "gas",
+		"gaps":         "gap",
+		"dying":        "die",
+		"corrupts":     "corrupt",
+		"corruption":   "corrupt",
+		"by":           "by",
+	}
+
+	for word, want := range cases {
+		if got := analysis.StemEnglish(word); got != want {
+			t.Errorf("StemEnglish(%q) = %q; want %q", word, got, want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	got := analysis.Russian.Analyze("Ёлки и Палки, ёжики!")
+	want := []analysis.Token{{Term: "елк", Pos: 0}, {Term: "палк", Pos: 2}, {Term: "ежик", Pos: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Russian.Analyze = %v; want %v", got, want)
+	}
+
+	got = analysis.English.Analyze("The power of Love")
+	want = []analysis.Token{{Term: "power", Pos: 1}, {Term: "love", Pos: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("English.Analyze = %v; want %v", got, want)
+	}
+
+	// английское слово в русском тексте приводится английским стеммером
+	if got := analysis.Russian.Terms("цитаты о freedoms"); !reflect.DeepEqual(got, []string{"цитат", "freedom"}) {
+		t.Errorf("Russian.Terms = %v", got)
+	}
+}
+
+func TestFoldingNormalize(t *testing.T) {
+	same := [][2]string{
+		{"  Лев   Толстой ", "лев толстой"},
+		{"Ёжиков", "ЕЖИКОВ"},
+		{"А. Эйнштейн", "а.  эйнштейн"},
+	}
+	for _, p := range same {
+		if a, b := analysis.Folding.Normalize(p[0]), analysis.Folding.Normalize(p[1]); a != b {
+			t.Errorf("Folding.Normalize(%q) = %q, Folding.Normalize(%q) = %q; want equal", p[0], a, p[1], b)
+		}
+	}
+
+	// пунктуация и формы слов не сворачиваются
+	different := [][2]string{{"А. Эйнштейн", "А Эйнштейн"}, {"Лев", "Львы"}}
+	for _, p := range different {
+		if analysis.Folding.Normalize(p[0]) == analysis.Folding.Normalize(p[1]) {
+			t.Errorf("Folding.Normalize(%q) and Folding.Normalize(%q) are equal", p[0], p[1])
+		}
+	}
+}
+
+func TestByScript(t *testing.T) {
+	cases := []struct {
+		text string
+		want *analysis.Analyzer
+	}{
+		{"Не всё то золото, что блестит", analysis.Russian},
+		{"All that glitters is not gold", analysis.English},
+		{"Быть или не быть - to be", analysis.Russian},
+		{"1984", analysis.Russian},
+	}
+
+	for _, c := range cases {
+		if got := analysis.ByScript(c.text); got != c.want {
+			t.Errorf("ByScript(%q) picked the wrong analyzer", c.text)
+		}
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	pairs := [][2]string{
+		{"Confucius", "CONFUCIUS"},
+		{"Лев Толстой", "лев толстой"},
+		{"Straße", "STRAßE"},
+		{"ſ", "S"},
+	}
+
+	for _, p := range pairs {
+		a, _ := analysis.FoldCase(p[0])
+		b, _ := analysis.FoldCase(p[1])
+		if a != b {
+			t.Errorf("FoldCase(%q) = %q, FoldCase(%q) = %q; want equal", p[0], a, p[1], b)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package analysis
+
+import "strings"
+
+// слова, которые алгоритм Porter2 обрабатывает особо, до всех шагов
+var enExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias",
+	"andes": "andes",
+}
+
+// после шага 1a эти слова больше не меняются
+var enInvariantAfter1a = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true, "earring": true,
+	"proceed": true, "exceed": true, "succeed": true,
+}
+
+type enRule struct {
+	suffix, replacement string
+}
+
+var (
+	enStep2 = []enRule{
+		{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"}, {"abli", "able"},
+		{"entli", "ent"}, {"ization", "ize"}, {"izer", "ize"}, {"ation", "ate"}, {"ator", "ate"},
+		{"alism", "al"}, {"aliti", "al"}, {"alli", "al"}, {"fulness", "ful"}, {"ousli", "ous"},
+		{"ousness", "ous"}, {"iveness", "ive"}, {"iviti", "ive"}, {"biliti", "ble"}, {"bli", "ble"},
+		{"ogi", "og"}, {"fulli", "ful"}, {"lessli", "less"}, {"li", ""},
+	}
+	enStep3 = []enRule{
+		{"ational", "ate"}, {"tional", "tion"}, {"alize", "al"}, {"icate", "ic"}, {"iciti", "ic"},
+		{"ical", "ic"}, {"ful", ""}, {"ness", ""}, {"ative", ""},
+	}
+	enStep4 = []string{
+		"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent", "ism", "ate",
+		"iti", "ous", "ive", "ize", "ion",
+	}
+)
+
+// основа слова по алгоритму Porter2 (Snowball English); слово должно быть в нижнем регистре.
+// Апострофы токенизатор уже отрезал, поэтому шаг 0 не нужен
+func StemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	if stem, ok := enExceptions[word]; ok {
+		return stem
+	}
+
+	w := []byte(word)
+	// y в начале и после гласной - согласная, помечаем ее как Y
+	for i := range w {
+		if w[i] == 'y' && (i == 0 || isEnVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+	s := &enStemmer{w: w}
+	s.r1, s.r2 = enRegions(w)
+
+	s.step1a()
+	if enInvariantAfter1a[string(s.w)] {
+		return string(s.w)
+	}
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+
+	return strings.ReplaceAll(string(s.w), "Y", "y")
+}
+
+type enStemmer struct {
+	w      []byte
+	r1, r2 int
+}
+
+func isEnVowel(c byte) bool {
+	return strings.IndexByte("aeiouy", c) >= 0
+}
+
+func enRegions(w []byte) (r1, r2 int) {
+	r1 = len(w)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w), prefix) {
+			r1 = len(prefix)
+			break
+		}
+	}
+	if r1 == len(w) {
+		r1 = enNextRegion(w, 0)
+	}
+	return r1, enNextRegion(w, r1)
+}
+
+// позиция после первой согласной, следующей за гласной, начиная с from
+func enNextRegion(w []byte, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isEnVowel(w[i]) && isEnVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func (s *enStemmer) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(s.w), suffix)
+}
+
+func (s *enStemmer) replace(suffix, replacement string) {
+	s.w = append(s.w[:len(s.w)-len(suffix)], replacement...)
+}
+
+// самое длинное подходящее правило; условие региона проверяет вызывающий
+func (s *enStemmer) longest(rules []enRule) (enRule, bool) {
+	var best enRule
+	found := false
+	for _, rule := range rules {
+		if s.hasSuffix(rule.suffix) && (!found || len(rule.suffix) > len(best.suffix)) {
+			best, found = rule, true
+		}
+	}
+	return best, found
+}
+
+// короткий слог в конце w[:end]: согласная-гласная-согласная (последняя не w, x, Y)
+// или гласная-согласная в начале слова
+func enShortSyllable(w []byte, end int) bool {
+	switch {
+	case end >= 3:
+		c := w[end-1]
+		return !isEnVowel(c) && c != 'w' && c != 'x' && c != 'Y' && isEnVowel(w[end-2]) && !isEnVowel(w[end-3])
+	case end == 2:
+		return isEnVowel(w[0]) && !isEnVowel(w[1])
+	default:
+		return false
+	}
+}
+
+func (s *enStemmer) isShort() bool {
+	return s.r1 >= len(s.w) && enShortSyllable(s.w, len(s.w))
+}
+
+func (s *enStemmer) containsVowel(end int) bool {
+	for _, c := range s.w[:end] {
+		if isEnVowel(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *enStemmer) step1a() {
+	switch {
+	case s.hasSuffix("sses"):
+		s.replace("sses", "ss")
+	case s.hasSuffix("ied"), s.hasSuffix("ies"):
+		suffix := string(s.w[len(s.w)-3:])
+		if len(s.w) > 4 {
+			s.replace(suffix, "i")
+		} else {
+			s.replace(suffix, "ie")
+		}
+	case s.hasSuffix("us"), s.hasSuffix("ss"):
+	case s.hasSuffix("s"):
+		// гласная должна быть не прямо перед s: gas и this не меняются
+		if s.containsVowel(len(s.w) - 2) {
+			s.w = s.w[:len(s.w)-1]
+		}
+	}
+}
+
+func (s *enStemmer) step1b() {
+	for _, suffix := range []string{"eedly", "eed"} {
+		if s.hasSuffix(suffix) {
+			if len(s.w)-len(suffix) >= s.r1 {
+				s.replace(suffix, "ee")
+			}
+			return
+		}
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if !s.hasSuffix(suffix) {
+			continue
+		}
+		if !s.containsVowel(len(s.w) - len(suffix)) {
+			return
+		}
+		s.w = s.w[:len(s.w)-len(suffix)]
+
+		switch {
+		case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+			s.w = append(s.w, 'e')
+		case enDoubleEnding(s.w):
+			s.w = s.w[:len(s.w)-1]
+		case s.isShort():
+			s.w = append(s.w, 'e')
+		}
+		return
+	}
+}
+
+func enDoubleEnding(w []byte) bool {
+	if len(w) < 2 || w[len(w)-1] != w[len(w)-2] {
+		return false
+	}
+	return strings.IndexByte("bdfgmnprt", w[len(w)-1]) >= 0
+}
+
+func (s *enStemmer) step1c() {
+	n := len(s.w)
+	if n > 2 && (s.w[n-1] == 'y' || s.w[n-1] == 'Y') && !isEnVowel(s.w[n-2]) {
+		s.w[n-1] = 'i'
+	}
+}
+
+func (s *enStemmer) step2() {
+	rule, ok := s.longest(enStep2)
+	if !ok || len(s.w)-len(rule.suffix) < s.r1 {
+		return
+	}
+
+	before := len(s.w) - len(rule.suffix) - 1
+	switch rule.suffix {
+	case "ogi":
+		if before < 0 || s.w[before] != 'l' {
+			return
+		}
+	case "li":
+		if before < 0 || strings.IndexByte("cdeghkmnrt", s.w[before]) < 0 {
+			return
+		}
+	}
+	s.replace(rule.suffix, rule.replacement)
+}
+
+func (s *enStemmer) step3() {
+	rule, ok := s.longest(enStep3)
+	if !ok || len(s.w)-len(rule.suffix) < s.r1 {
+		return
+	}
+	if rule.suffix == "ative" && len(s.w)-len(rule.suffix) < s.r2 {
+		return
+	}
+	s.replace(rule.suffix, rule.replacement)
+}
+
+func (s *enStemmer) step4() {
+	best := ""
+	for _, suffix := range enStep4 {
+		if s.hasSuffix(suffix) && len(suffix) > len(best) {
+			best = suffix
+		}
+	}
+	start := len(s.w) - len(best)
+	if best == "" || start < s.r2 {
+		return
+	}
+	if best == "ion" && (start == 0 || (s.w[start-1] != 's' && s.w[start-1] != 't')) {
+		return
+	}
+	s.w = s.w[:start]
+}
+
+func (s *enStemmer) step5() {
+	n := len(s.w)
+	switch {
+	case s.hasSuffix("e"):
+		if n-1 >= s.r2 || (n-1 >= s.r1 && !enShortSyllable(s.w, n-1)) {
+			s.w = s.w[:n-1]
+		}
+	case s.hasSuffix("l"):
+		if n-1 >= s.r2 && n >= 2 && s.w[n-2] == 'l' {
+			s.w = s.w[:n-1]
+		}
+	}
+}
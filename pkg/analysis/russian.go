@@ -0,0 +1,170 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+)
+
+// окончания по алгоритму Snowball для русского языка; ё заранее заменена на е
+var (
+	ruPerfectiveGerund = []ruGroup{
+		{endings: []string{"в", "вши", "вшись"}, afterAYa: true},
+		{endings: []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}},
+	}
+	ruAdjective = []ruGroup{
+		{endings: []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+			"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}},
+	}
+	ruParticiple = []ruGroup{
+		{endings: []string{"ем", "нн", "вш", "ющ", "щ"}, afterAYa: true},
+		{endings: []string{"ивш", "ывш", "ующ"}},
+	}
+	ruReflexive = []ruGroup{
+		{endings: []string{"ся", "сь"}},
+	}
+	ruVerb = []ruGroup{
+		{endings: []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны",
+			"ть", "ешь", "нно"}, afterAYa: true},
+		{endings: []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл",
+			"им", "ым", "ен", "ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь",
+			"ую", "ю"}},
+	}
+	ruNoun = []ruGroup{
+		{endings: []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей",
+			"ой", "ий", "й", "иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию",
+			"ью", "ю", "ия", "ья", "я"}},
+	}
+	ruDerivational = []ruGroup{
+		{endings: []string{"ост", "ость"}},
+	}
+)
+
+// Snowball не знает беглых гласных: любовь -> любов, любви -> любв.
+// Для частых слов сводим такие основы к одной вручную
+var ruStemOverrides = map[string]string{
+	"любов":  "любв",
+	"церков": "церкв",
+	"отец":   "отц",
+	"конец":  "конц",
+	"огон":   "огн",
+	"ветер":  "ветр",
+}
+
+type ruGroup struct {
+	endings  []string
+	afterAYa bool // окончание отрезается только после а или я
+}
+
+func isRuVowel(r rune) bool {
+	return strings.ContainsRune("аеиоуыэюя", r)
+}
+
+// основа слова по алгоритму Snowball; слово должно быть в нижнем регистре
+func StemRussian(word string) string {
+	w := []rune(word)
+	rv, r2 := ruRegions(w)
+
+	// шаг 1: деепричастие, иначе возвратная частица и прилагательное, глагол или существительное
+	if stem, ok := ruRemove(w, rv, ruPerfectiveGerund); ok {
+		w = stem
+	} else {
+		if stem, ok := ruRemove(w, rv, ruReflexive); ok {
+			w = stem
+		}
+		if stem, ok := ruRemove(w, rv, ruAdjective); ok {
+			w = stem
+			if stem, ok := ruRemove(w, rv, ruParticiple); ok {
+				w = stem
+			}
+		} else if stem, ok := ruRemove(w, rv, ruVerb); ok {
+			w = stem
+		} else if stem, ok := ruRemove(w, rv, ruNoun); ok {
+			w = stem
+		}
+	}
+
+	// шаг 2
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+
+	// шаг 3: словообразовательный суффикс целиком в R2
+	if stem, ok := ruRemove(w, max(rv, r2), ruDerivational); ok {
+		w = stem
+	}
+
+	// шаг 4: превосходная степень, двойное н, мягкий знак
+	switch {
+	case ruHasSuffix(w, rv, "ейше"):
+		w = ruUndoubleN(w[:len(w)-4], rv)
+	case ruHasSuffix(w, rv, "ейш"):
+		w = ruUndoubleN(w[:len(w)-3], rv)
+	case ruHasSuffix(w, rv, "нн"):
+		w = w[:len(w)-1]
+	case ruHasSuffix(w, rv, "ь"):
+		w = w[:len(w)-1]
+	}
+
+	stem := string(w)
+	if override, ok := ruStemOverrides[stem]; ok {
+		return override
+	}
+	return stem
+}
+
+// RV - после первой гласной; R2 - после сочетания гласная+согласная, встреченного дважды
+func ruRegions(w []rune) (rv, r2 int) {
+	rv = len(w)
+	for i, r := range w {
+		if isRuVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r1 := ruNextRegion(w, 0)
+	return rv, ruNextRegion(w, r1)
+}
+
+func ruNextRegion(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isRuVowel(w[i]) && isRuVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func ruHasSuffix(w []rune, start int, suffix string) bool {
+	s := []rune(suffix)
+	return len(w)-len(s) >= start && slices.Equal(w[len(w)-len(s):], s)
+}
+
+// отрезает самое длинное окончание из групп, лежащее не левее start.
+// Как в Snowball, если самое длинное не подходит по условию, более короткие не пробуем
+func ruRemove(w []rune, start int, groups []ruGroup) ([]rune, bool) {
+	best, bestGroup := -1, -1
+	for g, group := range groups {
+		for _, ending := range group.endings {
+			n := len([]rune(ending))
+			if n > best && ruHasSuffix(w, start, ending) {
+				best, bestGroup = n, g
+			}
+		}
+	}
+	if best < 0 {
+		return w, false
+	}
+
+	cut := len(w) - best
+	if groups[bestGroup].afterAYa && (cut-1 < start || (w[cut-1] != 'а' && w[cut-1] != 'я')) {
+		return w, false
+	}
+	return w[:cut], true
+}
+
+func ruUndoubleN(w []rune, rv int) []rune {
+	if ruHasSuffix(w, rv, "нн") {
+		return w[:len(w)-1]
+	}
+	return w
+}
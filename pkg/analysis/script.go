@@ -0,0 +1,46 @@
+package analysis
+
+import "unicode"
+
+type Script int
+
+const (
+	Unknown Script = iota
+	Cyrillic
+	Latin
+)
+
+// алфавит, букв которого в тексте больше
+func DetectScript(text string) Script {
+	cyrillic, latin := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case cyrillic == 0 && latin == 0:
+		return Unknown
+	case cyrillic >= latin:
+		return Cyrillic
+	default:
+		return Latin
+	}
+}
+
+// алфавит одного слова: по первой букве
+func scriptOf(word string) Script {
+	for _, r := range word {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			return Cyrillic
+		case unicode.Is(unicode.Latin, r):
+			return Latin
+		}
+	}
+	return Unknown
+}
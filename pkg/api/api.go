@@ -19,8 +19,8 @@ func (api *API) Router() *mux.Router {
 	return api.router
 }
 
-func New(db db.DB, logger *slog.Logger) *API {
-	quoteService := service.NewQuoteService(db)
+func New(db db.DB, logger *slog.Logger, opts ...service.Option) *API {
+	quoteService := service.NewQuoteService(db, opts...)
 
 	api := API{
 		router: mux.NewRouter(),
@@ -33,9 +33,23 @@ func New(db db.DB, logger *slog.Logger) *API {
 }
 
 func (api *API) endpoints(qs service.QuoteService) {
+	api.router.Use(handlers.RequestIDMiddleware)
+	api.router.NotFoundHandler = handlers.NewNotFoundHandler(api.logger)
+	api.router.MethodNotAllowedHandler = handlers.NewMethodNotAllowedHandler(api.router, api.logger)
 
 	api.router.HandleFunc("/quotes", handlers.NewAddQuoteHandler(qs, api.logger)).Methods(http.MethodPost)
 	api.router.HandleFunc("/quotes", handlers.NewGetQuotesHandler(qs, api.logger)).Methods(http.MethodGet)
 	api.router.HandleFunc("/quotes/random", handlers.NewGetRandomQuotesHandler(qs, api.logger)).Methods(http.MethodGet)
+	api.router.HandleFunc("/quotes/daily", handlers.NewGetDailyQuoteHandler(qs, api.logger)).Methods(http.MethodGet)
+	api.router.HandleFunc("/quotes/search", handlers.NewSearchQuotesHandler(qs, api.logger)).Methods(http.MethodGet)
+	api.router.HandleFunc("/quotes/{id}", handlers.NewGetQuoteHandler(qs, api.logger)).Methods(http.MethodGet)
+	api.router.HandleFunc("/quotes/{id}", handlers.NewUpdateQuoteHandler(qs, api.logger)).Methods(http.MethodPut)
+	api.router.HandleFunc("/quotes/{id}", handlers.NewPatchQuoteHandler(qs, api.logger)).Methods(http.MethodPatch)
 	api.router.HandleFunc("/quotes/{id}", handlers.NewDeleteQuoteHandler(qs, api.logger)).Methods(http.MethodDelete)
+	api.router.HandleFunc("/quotes/{id}/tags/{tag}", handlers.NewAddTagHandler(qs, api.logger)).Methods(http.MethodPut)
+	api.router.HandleFunc("/quotes/{id}/tags/{tag}", handlers.NewRemoveTagHandler(qs, api.logger)).Methods(http.MethodDelete)
+	api.router.HandleFunc("/tags", handlers.NewGetTagsHandler(qs, api.logger)).Methods(http.MethodGet)
+	api.router.HandleFunc("/authors", handlers.NewGetAuthorsHandler(qs, api.logger)).Methods(http.MethodGet)
+	api.router.HandleFunc("/authors/suggest", handlers.NewSuggestAuthorsHandler(qs, api.logger)).Methods(http.MethodGet)
+	api.router.HandleFunc("/authors/{name}/merge", handlers.NewMergeAuthorsHandler(qs, api.logger)).Methods(http.MethodPost)
 }
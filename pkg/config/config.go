@@ -12,13 +12,31 @@ type ServerConfig struct {
 	IdleTimeout  int    `json:"idle_timeout"`  // В секундах
 }
 
+type WALConfig struct {
+	Path           string `json:"path"`             // Пустой путь - журнал выключен
+	Sync           string `json:"sync"`             // always, interval или never
+	SyncIntervalMs int    `json:"sync_interval_ms"` // В миллисекундах, для sync = interval
+}
+
+type SnapshotConfig struct {
+	Dir         string `json:"dir"`          // Пустой каталог - снапшоты выключены
+	IntervalSec int    `json:"interval_sec"` // В секундах
+}
+
 type DatabaseConfig struct {
-	Type string `json:"type"`
+	Type     string         `json:"type"`
+	WAL      WALConfig      `json:"wal"`
+	Snapshot SnapshotConfig `json:"snapshot"`
+}
+
+type DailyConfig struct {
+	TimeZone string `json:"time_zone"` // Имя из базы IANA, например Europe/Moscow; пусто - UTC
 }
 
 type Config struct {
 	Server   ServerConfig   `json:"server"`
 	Database DatabaseConfig `json:"database"`
+	Daily    DailyConfig    `json:"daily"`
 }
 
 func MustLoad(fp string) (*Config, error) {
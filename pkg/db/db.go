@@ -1,11 +1,26 @@
 package db
 
-import "quote_book/pkg/entities"
+import (
+	"context"
+	"quote_book/pkg/entities"
+)
 
 type DB interface {
-	AddQuote(quote entities.Quote) error
-	GetAllQuotes() ([]entities.Quote, error)
-	GetRandomQuote() (entities.Quote, error)
-	GetAuthorQuotes(author string) ([]entities.Quote, error)
-	DeleteQuote(id int) error
+	AddQuote(ctx context.Context, quote entities.Quote, opts ...entities.AddOption) error
+	GetAllQuotes(ctx context.Context) ([]entities.Quote, error)
+	GetQuoteByID(ctx context.Context, id int) (entities.Quote, error)
+	SearchQuotes(ctx context.Context, text string, limit int) ([]entities.SearchResult, error)
+	GetRandomQuote(ctx context.Context, q entities.RandomQuery) (entities.Quote, error)
+	GetRandomQuotes(ctx context.Context, q entities.RandomQuery, n int) ([]entities.Quote, error)
+	GetDailyQuote(ctx context.Context, day string) (entities.Quote, error)
+	PickDailyQuote(ctx context.Context, day string) (entities.Quote, error)
+	GetAuthorQuotes(ctx context.Context, author string) ([]entities.Quote, error)
+	SuggestAuthors(ctx context.Context, prefix string, limit int) ([]entities.AuthorCount, error)
+	MergeAuthors(ctx context.Context, from, into string) (entities.AuthorCount, error)
+	ListAuthors(ctx context.Context, q entities.AuthorQuery, after *entities.AuthorPosition, limit int) ([]entities.AuthorCount, bool, error)
+	ListQuotes(ctx context.Context, q entities.QuoteQuery, after *entities.Position, limit int) ([]entities.Quote, bool, error)
+	UpdateQuote(ctx context.Context, id int, update func(*entities.Quote) error) (entities.Quote, error)
+	DeleteQuote(ctx context.Context, id int) error
+	ListTags(ctx context.Context) ([]entities.TagCount, error)
+	Close() error
 }
@@ -0,0 +1,351 @@
+package memdb
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"quote_book/pkg/analysis"
+	"quote_book/pkg/entities"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// цитаты одного автора. Меняется под блокировкой базы на запись,
+// кроме счетчика живых цитат: логическое удаление идет под блокировкой на чтение
+type authorEntry struct {
+	name   string             // написание, под которым автор впервые появился в базе
+	quotes map[int]*safeQuote // включая удаленные, но еще не собранные GC
+	live   atomic.Int64
+	ids    liveIDs // живые цитаты, для случайного выбора; при удалении меняется под randomMu
+	slot   int     // слот в дереве авторов randomIndex, пока есть живые цитаты
+}
+
+// строка префиксного индекса: ключ автора, начиная с одного из его слов,
+// чтобы подсказка находила "Лев Толстой" и по "лев", и по "толст"
+type authorPrefix struct {
+	text string
+	key  string
+}
+
+func comparePrefixes(a, b authorPrefix) int {
+	if c := cmp.Compare(a.text, b.text); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.key, b.key)
+}
+
+// ключ автора в индексе: без крайних и повторных пробелов, без учета регистра и различия ё/е.
+// В цитате автор хранится как был введен
+func authorKey(author string) string {
+	return analysis.Folding.Normalize(author)
+}
+
+// ключ автора с учетом псевдонимов; псевдоним всегда указывает сразу на канонического автора.
+// Вызывается под блокировкой базы
+func (db *MemDB) resolveAuthor(author string) string {
+	key := authorKey(author)
+	if canonical, ok := db.aliases[key]; ok {
+		return canonical
+	}
+	return key
+}
+
+// вызывается под блокировкой на запись или при восстановлении
+func (db *MemDB) indexAuthor(sQuote *safeQuote) {
+	entry := db.authorIndex[sQuote.authorKey]
+	if entry == nil {
+		entry = &authorEntry{
+			name:   strings.Join(strings.Fields(sQuote.Author), " "),
+			quotes: make(map[int]*safeQuote),
+		}
+		db.authorIndex[sQuote.authorKey] = entry
+		db.addPrefixes(sQuote.authorKey)
+	}
+	entry.quotes[sQuote.ID] = sQuote
+	if !sQuote.deleted {
+		entry.live.Add(1)
+		db.random.addAuthorID(entry, sQuote.ID)
+	}
+}
+
+// вызывается под блокировкой на запись
+func (db *MemDB) unindexAuthor(sQuote *safeQuote) {
+	entry := db.authorIndex[sQuote.authorKey]
+	if entry == nil {
+		return
+	}
+	delete(entry.quotes, sQuote.ID)
+	if !sQuote.deleted {
+		entry.live.Add(-1)
+		db.random.removeAuthorID(entry, sQuote.ID)
+	}
+	if len(entry.quotes) == 0 {
+		delete(db.authorIndex, sQuote.authorKey)
+		db.removePrefixes(sQuote.authorKey)
+	}
+}
+
+func wordStarts(key string) []string {
+	starts := []string{key}
+	for i, r := range key {
+		if r == ' ' {
+			starts = append(starts, key[i+1:])
+		}
+	}
+	return starts
+}
+
+func (db *MemDB) addPrefixes(key string) {
+	for _, text := range wordStarts(key) {
+		p := authorPrefix{text: text, key: key}
+		i, _ := slices.BinarySearchFunc(db.authorPrefixes, p, comparePrefixes)
+		db.authorPrefixes = slices.Insert(db.authorPrefixes, i, p)
+	}
+}
+
+func (db *MemDB) removePrefixes(key string) {
+	for _, text := range wordStarts(key) {
+		i, found := slices.BinarySearchFunc(db.authorPrefixes, authorPrefix{text: text, key: key}, comparePrefixes)
+		if found {
+			db.authorPrefixes = slices.Delete(db.authorPrefixes, i, i+1)
+		}
+	}
+}
+
+// до limit авторов, одно из слов которых начинается с prefix, по убыванию числа живых цитат
+func (db *MemDB) SuggestAuthors(ctx context.Context, prefix string, limit int) ([]entities.AuthorCount, error) {
+	prefix = authorKey(prefix)
+	if prefix == "" {
+		return nil, entities.Errorf(entities.ErrValidation, "empty prefix")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	start := sort.Search(len(db.authorPrefixes), func(i int) bool { return db.authorPrefixes[i].text >= prefix })
+	seen := make(map[string]bool)
+	// лучше - больше цитат, при равенстве раньше по алфавиту
+	top := &authorHeap{compare: func(a, b authorItem) int { return -compareAuthorCounts(a.AuthorCount, b.AuthorCount) }}
+	for i := start; i < len(db.authorPrefixes) && strings.HasPrefix(db.authorPrefixes[i].text, prefix); i++ {
+		if (i-start+1)%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		key := db.authorPrefixes[i].key
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		entry := db.authorIndex[key]
+		live := int(entry.live.Load())
+		if live == 0 {
+			continue
+		}
+		heap.Push(top, authorItem{AuthorCount: entities.AuthorCount{Author: entry.name, Quotes: live}, key: key})
+		if top.Len() > limit {
+			heap.Pop(top)
+		}
+	}
+
+	return top.sorted(), nil
+}
+
+func compareAuthorCounts(a, b entities.AuthorCount) int {
+	if c := cmp.Compare(a.Quotes, b.Quotes); c != 0 {
+		return c
+	}
+	return cmp.Compare(b.Author, a.Author)
+}
+
+// до limit авторов с живыми цитатами в порядке q после позиции after (nil - с начала); more - есть ли авторы дальше.
+// Число цитат берется из счетчиков индекса, сами цитаты не перебираются
+func (db *MemDB) ListAuthors(ctx context.Context, q entities.AuthorQuery, after *entities.AuthorPosition, limit int) ([]entities.AuthorCount, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	top := &authorHeap{compare: authorItemCompare(q)}
+	var from *authorItem
+	if after != nil {
+		// ключ курсора считаем один раз, у авторов из индекса он уже есть
+		from = &authorItem{AuthorCount: entities.AuthorCount{Author: after.Name, Quotes: after.Quotes}, key: db.resolveAuthor(after.Name)}
+	}
+	checked := 0
+	for key, entry := range db.authorIndex {
+		checked++
+		if checked%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, false, err
+			}
+		}
+
+		live := int(entry.live.Load())
+		if live == 0 {
+			continue
+		}
+		author := authorItem{AuthorCount: entities.AuthorCount{Author: entry.name, Quotes: live}, key: key}
+		if from != nil && top.compare(author, *from) <= 0 {
+			continue
+		}
+
+		heap.Push(top, author)
+		if top.Len() > limit+1 {
+			heap.Pop(top)
+		}
+	}
+
+	authors := top.sorted()
+	more := len(authors) > limit
+	if more {
+		authors = authors[:limit]
+	}
+	return authors, more, nil
+}
+
+// автор в выдаче с ключом индекса, чтобы не пересчитывать ключ из имени при каждом сравнении
+type authorItem struct {
+	entities.AuthorCount
+	key string
+}
+
+// сравнение в порядке запроса: имя без учета регистра (по ключу), при равенстве - как написано, чтобы порядок был полным
+func authorItemCompare(q entities.AuthorQuery) func(a, b authorItem) int {
+	return func(a, b authorItem) int {
+		var c int
+		if q.SortBy == entities.AuthorSortByCount {
+			c = cmp.Compare(a.Quotes, b.Quotes)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.key, b.key)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Author, b.Author)
+		}
+		if q.Desc {
+			c = -c
+		}
+		return c
+	}
+}
+
+// куча с последним по порядку compare автором наверху - его выбрасываем при переполнении
+type authorHeap struct {
+	items   []authorItem
+	compare func(a, b authorItem) int
+}
+
+func (h *authorHeap) Len() int { return len(h.items) }
+func (h *authorHeap) Less(i, j int) bool {
+	return h.compare(h.items[i], h.items[j]) > 0
+}
+func (h *authorHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *authorHeap) Push(x any)    { h.items = append(h.items, x.(authorItem)) }
+func (h *authorHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// авторы из кучи в порядке compare
+func (h *authorHeap) sorted() []entities.AuthorCount {
+	slices.SortFunc(h.items, h.compare)
+	var authors []entities.AuthorCount
+	for _, item := range h.items {
+		authors = append(authors, item.AuthorCount)
+	}
+	return authors
+}
+
+// переписывает все цитаты автора from на автора into и делает from его псевдонимом.
+// Оба имени могут быть псевдонимами - сливаются канонические авторы.
+// Слияние идет одной записью журнала под блокировкой на запись, поэтому читатели видят либо всех авторов до, либо после.
+// Неизвестный автор - ErrNotFound: иначе опечатка навсегда завела бы псевдоним
+func (db *MemDB) MergeAuthors(ctx context.Context, from, into string) (entities.AuthorCount, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return entities.AuthorCount{}, err
+	}
+
+	fromKey, intoKey := db.resolveAuthor(from), db.resolveAuthor(into)
+	if fromKey == "" || intoKey == "" {
+		return entities.AuthorCount{}, entities.Errorf(entities.ErrValidation, "empty author")
+	}
+	if fromKey == intoKey {
+		return entities.AuthorCount{}, entities.Errorf(entities.ErrValidation, "cannot merge author %q into itself", from)
+	}
+	if _, alias := db.aliases[authorKey(from)]; !alias && db.authorIndex[fromKey] == nil {
+		return entities.AuthorCount{}, entities.Errorf(entities.ErrNotFound, "author %q not found", from)
+	}
+
+	at := db.now()
+	if err := db.logRecord(walRecord{Op: opMerge, From: from, Into: into, At: &at}); err != nil {
+		return entities.AuthorCount{}, err
+	}
+	return db.applyMerge(from, into, at), nil
+}
+
+// переписанным цитатам ставится updated_at = at; нулевое at (записи журнала до появления времени) его не меняет.
+// Вызывается под блокировкой на запись или при проигрывании журнала
+func (db *MemDB) applyMerge(from, into string, at time.Time) entities.AuthorCount {
+	fromKey, intoKey := db.resolveAuthor(from), db.resolveAuthor(into)
+	if fromKey == intoKey {
+		return entities.AuthorCount{}
+	}
+
+	target := db.authorIndex[intoKey]
+	if source := db.authorIndex[fromKey]; source != nil {
+		if target == nil {
+			target = &authorEntry{name: strings.Join(strings.Fields(into), " "), quotes: make(map[int]*safeQuote)}
+			db.authorIndex[intoKey] = target
+			db.addPrefixes(intoKey)
+		}
+
+		for id, sQuote := range source.quotes {
+			db.fingerprints.remove(sQuote)
+			quote := *sQuote.Quote
+			quote.Author = target.name
+			if !at.IsZero() {
+				quote.UpdatedAt = at
+			}
+			sQuote.Quote = &quote
+			sQuote.authorKey = intoKey
+			db.fingerprints.add(sQuote)
+			target.quotes[id] = sQuote
+			if !sQuote.deleted {
+				db.random.addAuthorID(target, id)
+			}
+		}
+		if source.ids.count() > 0 {
+			db.random.releaseAuthor(source)
+		}
+		target.live.Add(source.live.Load())
+		delete(db.authorIndex, fromKey)
+		db.removePrefixes(fromKey)
+	}
+
+	for alias, canonical := range db.aliases {
+		if canonical == fromKey {
+			db.aliases[alias] = intoKey
+		}
+	}
+	db.aliases[fromKey] = intoKey
+
+	if target == nil {
+		return entities.AuthorCount{Author: strings.Join(strings.Fields(into), " ")}
+	}
+	return entities.AuthorCount{Author: target.name, Quotes: int(target.live.Load())}
+}
@@ -0,0 +1,202 @@
+package memdb_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSuggestAuthors(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q1", Author: "Лев Толстой"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q2", Author: "лев толстой"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q3", Author: "Алексей Толстой"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q4", Author: "Лермонтов"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q5", Author: "Толстой"})
+
+	// Совпадение с началом любого слова, больше цитат - выше
+	authors, err := db.SuggestAuthors(ctx, "ТОЛСТ", 10)
+	if err != nil {
+		t.Fatalf("SuggestAuthors failed: %v", err)
+	}
+	want := []entities.AuthorCount{
+		{Author: "Лев Толстой", Quotes: 2},
+		{Author: "Алексей Толстой", Quotes: 1},
+		{Author: "Толстой", Quotes: 1},
+	}
+	if !reflect.DeepEqual(authors, want) {
+		t.Fatalf("SuggestAuthors expected %v, got %v", want, authors)
+	}
+
+	if authors, _ = db.SuggestAuthors(ctx, "ле", 1); len(authors) != 1 || authors[0].Author != "Лев Толстой" {
+		t.Fatalf("SuggestAuthors with limit: expected Лев Толстой, got %v", authors)
+	}
+
+	// Удаленные до GC цитаты не считаются, автор без живых цитат не подсказывается
+	_ = db.DeleteQuote(ctx, 1)
+	_ = db.DeleteQuote(ctx, 3)
+	authors, _ = db.SuggestAuthors(ctx, "л", 10)
+	want = []entities.AuthorCount{{Author: "Лев Толстой", Quotes: 1}}
+	if !reflect.DeepEqual(authors, want) {
+		t.Fatalf("after delete: expected %v, got %v", want, authors)
+	}
+
+	// Смена автора переносит цитату в подсказках
+	_, _ = db.UpdateQuote(ctx, 4, func(q *entities.Quote) error {
+		q.Author = "Лермонтов"
+		return nil
+	})
+	authors, _ = db.SuggestAuthors(ctx, "лерм", 10)
+	want = []entities.AuthorCount{{Author: "Лермонтов", Quotes: 1}}
+	if !reflect.DeepEqual(authors, want) {
+		t.Fatalf("after update: expected %v, got %v", want, authors)
+	}
+}
+
+func authorOf(t *testing.T, db *memdb.MemDB, id int) string {
+	t.Helper()
+
+	quote, err := db.GetQuoteByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetQuoteByID(%d) failed: %v", id, err)
+	}
+	return quote.Author
+}
+
+func TestMergeAuthors(t *testing.T) {
+	dir := t.TempDir()
+	opts := []memdb.Option{memdb.WithWAL(filepath.Join(dir, "quotes.wal")), memdb.WithSnapshots(dir, 0)}
+	ctx := context.Background()
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q1", Author: "Эйнштейн"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q2", Author: "А. Эйнштейн"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q3", Author: "Albert Einstein"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q4", Author: "Эйнштейн"})
+	_ = db.DeleteQuote(ctx, 3)
+
+	author, err := db.MergeAuthors(ctx, "эйнштейн", "Albert Einstein")
+	if err != nil {
+		t.Fatalf("MergeAuthors failed: %v", err)
+	}
+	if author != (entities.AuthorCount{Author: "Albert Einstein", Quotes: 2}) {
+		t.Fatalf("MergeAuthors returned %v", author)
+	}
+	if got := authorOf(t, db, 0); got != "Albert Einstein" {
+		t.Fatalf("merged quote has author %q", got)
+	}
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// Цепочка слияний: старый псевдоним указывает на нового автора
+	if _, err := db.MergeAuthors(ctx, "Albert Einstein", "А. Эйнштейн"); err != nil {
+		t.Fatalf("MergeAuthors failed: %v", err)
+	}
+	// Неизвестный автор не становится псевдонимом, даже если цель тоже неизвестна
+	for _, into := range []string{"а. эйнштейн", "Nobody"} {
+		if _, err := db.MergeAuthors(ctx, "A. Einstein", into); !errors.Is(err, entities.ErrNotFound) {
+			t.Fatalf("merge unknown author into %q: expected ErrNotFound, got %v", into, err)
+		}
+	}
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q6", Author: "A. Einstein"})
+	// Новые цитаты под псевдонимом попадают к каноническому автору
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q5", Author: "Эйнштейн"})
+
+	check := func(db *memdb.MemDB) {
+		t.Helper()
+
+		for _, alias := range []string{"Эйнштейн", "Albert Einstein", "а. эйнштейн"} {
+			quotes, _ := db.GetAuthorQuotes(ctx, alias)
+			if len(quotes) != 4 {
+				t.Fatalf("GetAuthorQuotes(%q): expected 4 quotes, got %v", alias, quotes)
+			}
+		}
+		if got := authorOf(t, db, 0); got != "А. Эйнштейн" {
+			t.Fatalf("merged quote has author %q", got)
+		}
+		if got := authorOf(t, db, 5); got != "Эйнштейн" {
+			t.Fatalf("quote added under alias changed author to %q", got)
+		}
+		if got := authorOf(t, db, 4); got != "A. Einstein" {
+			t.Fatalf("quote of unknown merged author changed author to %q", got)
+		}
+		if quotes, _ := db.GetAuthorQuotes(ctx, "Nobody"); len(quotes) != 0 {
+			t.Fatalf("merge of unknown author created target with %v", quotes)
+		}
+		authors, _ := db.SuggestAuthors(ctx, "эйн", 10)
+		want := []entities.AuthorCount{{Author: "А. Эйнштейн", Quotes: 4}}
+		if !reflect.DeepEqual(authors, want) {
+			t.Fatalf("SuggestAuthors expected %v, got %v", want, authors)
+		}
+	}
+	check(db)
+
+	_, err = db.MergeAuthors(ctx, "А.  Эйнштейн", "а. эйнштейн")
+	if !errors.Is(err, entities.ErrValidation) {
+		t.Fatalf("merge into itself: expected ErrValidation, got %v", err)
+	}
+
+	// После перезапуска слияния восстанавливаются из снапшота и журнала
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db = newTestDB(t, opts...)
+	check(db)
+
+	// Слияние по псевдониму переносит цитаты канонического автора, а не только сам псевдоним
+	author, err = db.MergeAuthors(ctx, "Albert Einstein", "Einstein")
+	if err != nil {
+		t.Fatalf("MergeAuthors by alias failed: %v", err)
+	}
+	if author != (entities.AuthorCount{Author: "Einstein", Quotes: 4}) {
+		t.Fatalf("MergeAuthors by alias returned %v", author)
+	}
+	for _, alias := range []string{"Эйнштейн", "а. эйнштейн", "einstein"} {
+		if quotes, _ := db.GetAuthorQuotes(ctx, alias); len(quotes) != 4 || quotes[0].Author != "Einstein" {
+			t.Fatalf("GetAuthorQuotes(%q) after merge by alias: unexpected %v", alias, quotes)
+		}
+	}
+}
+
+func TestListAuthors(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	for i, author := range []string{"Пушкин", "Лермонтов", "пушкин", "Гоголь", "Гоголь", "Гоголь"} {
+		_ = db.AddQuote(ctx, entities.Quote{Text: "Q" + strconv.Itoa(i), Author: author})
+	}
+	_ = db.DeleteQuote(ctx, 1)
+
+	query := entities.AuthorQuery{SortBy: entities.AuthorSortByName}
+	authors, more, err := db.ListAuthors(ctx, query, nil, 10)
+	if err != nil {
+		t.Fatalf("ListAuthors failed: %v", err)
+	}
+	want := []entities.AuthorCount{{Author: "Гоголь", Quotes: 3}, {Author: "Пушкин", Quotes: 2}}
+	if more || !reflect.DeepEqual(authors, want) {
+		t.Fatalf("ListAuthors expected %v, got %v (more %v)", want, authors, more)
+	}
+
+	// Вторая страница продолжается с позиции последнего автора первой
+	query = entities.AuthorQuery{SortBy: entities.AuthorSortByCount}
+	authors, more, _ = db.ListAuthors(ctx, query, nil, 1)
+	if !more || len(authors) != 1 || authors[0].Author != "Пушкин" {
+		t.Fatalf("ListAuthors first page: unexpected %v (more %v)", authors, more)
+	}
+	after := entities.AuthorPosition{Name: authors[0].Author, Quotes: authors[0].Quotes}
+	authors, more, _ = db.ListAuthors(ctx, query, &after, 1)
+	if more || len(authors) != 1 || authors[0].Author != "Гоголь" {
+		t.Fatalf("ListAuthors second page: unexpected %v (more %v)", authors, more)
+	}
+}
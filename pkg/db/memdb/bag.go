@@ -0,0 +1,117 @@
+package memdb
+
+import (
+	"container/list"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// мешок клиента: цитаты, уже вытянутые в текущем круге. Вытягивание случайной невытянутой
+// равносильно проходу по случайной перестановке, но память растет с числом показов, а не с размером базы.
+// Цитаты, добавленные посреди круга, попадают в него же, удаленные просто перестают быть кандидатами
+type shuffleBag struct {
+	mu    sync.Mutex
+	drawn liveIDs
+
+	// под блокировкой shuffleBags
+	token  string
+	usedAt time.Time
+	elem   *list.Element
+}
+
+// мешки живут только в памяти: после перезапуска клиенты начинают новый круг.
+// Мешки упорядочены по последнему запросу: истекшие и лишние сверх limit удаляются с давно не использованного конца
+type shuffleBags struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	limit int
+	bags  map[string]*shuffleBag
+	lru   *list.List // *shuffleBag, недавние в начале
+}
+
+func newShuffleBags(ttl time.Duration, limit int) *shuffleBags {
+	return &shuffleBags{ttl: ttl, limit: limit, bags: make(map[string]*shuffleBag), lru: list.New()}
+}
+
+// мешок по токену клиента. Блокировка мешка берется уже после общей,
+// чтобы долгое вытягивание одного клиента не задерживало остальных. Вызывающий разблокирует мешок
+func (b *shuffleBags) acquire(token string, now time.Time) *shuffleBag {
+	bag := b.touch(token, now)
+	bag.mu.Lock()
+	return bag
+}
+
+// находит или заводит мешок и отмечает запрос; истекшие и лишние мешки удаляются попутно
+func (b *shuffleBags) touch(token string, now time.Time) *shuffleBag {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// мешок самого клиента тоже может истечь - тогда он начнет новый круг
+	for e := b.lru.Back(); e != nil && now.Sub(e.Value.(*shuffleBag).usedAt) >= b.ttl; e = b.lru.Back() {
+		b.evict(e.Value.(*shuffleBag))
+	}
+
+	bag := b.bags[token]
+	if bag == nil {
+		// место под новый мешок освобождаем заранее, чтобы их всегда было не больше limit
+		if b.lru.Len() >= b.limit {
+			b.evict(b.lru.Back().Value.(*shuffleBag))
+		}
+		bag = &shuffleBag{token: token}
+		bag.elem = b.lru.PushFront(bag)
+		b.bags[token] = bag
+	} else {
+		b.lru.MoveToFront(bag.elem)
+	}
+	bag.usedAt = now
+	return bag
+}
+
+// удаленный мешок может еще вытягиваться в идущем запросе - тот просто допишет в него и забудет
+func (b *shuffleBags) evict(bag *shuffleBag) {
+	b.lru.Remove(bag.elem)
+	delete(b.bags, bag.token)
+}
+
+// n разных кандидатов, которых еще не было в круге. Когда невытянутых кандидатов не остается, начинается новый круг;
+// в него сразу входят уже отданные в этом ответе, чтобы ответ был без повторов.
+// С фильтрами круг идет по подходящим цитатам, вытянутые по другим фильтрам тоже считаются показанными
+func (bag *shuffleBag) draw(c orderedCandidates, n int, rng *rand.Rand) []int {
+	total := c.count()
+	n = min(n, total)
+
+	ids := make([]int, 0, n)
+	ranks := bag.drawnRanks(c)
+	for len(ids) < n {
+		unseen := total - len(ranks)
+		if unseen == 0 {
+			bag.drawn = liveIDs{ids: slices.Clone(ids)}
+			slices.Sort(bag.drawn.ids)
+			ranks = bag.drawnRanks(c)
+			continue
+		}
+
+		// номер среди невытянутых переводим в номер среди всех кандидатов, перешагивая вытянутые перед ним
+		k := skipRanks(rng.Intn(unseen), ranks)
+		i, _ := slices.BinarySearch(ranks, k)
+		ranks = slices.Insert(ranks, i, k)
+
+		id := c.at(k)
+		bag.drawn.add(id)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// номера вытянутых цитат среди кандидатов, по возрастанию; удаленные и не подходящие под фильтры не учитываются
+func (bag *shuffleBag) drawnRanks(c orderedCandidates) []int {
+	ranks := make([]int, 0, bag.drawn.count())
+	for _, id := range bag.drawn.ids {
+		if r, found := c.rank(id); found {
+			ranks = append(ranks, r)
+		}
+	}
+	return ranks
+}
@@ -0,0 +1,125 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"quote_book/pkg/entities"
+	"slices"
+)
+
+// ротация цитаты дня: какая цитата выпала в какой день и какие уже выпадали в текущем круге.
+// Меняется под блокировкой базы на запись
+type dailyRotation struct {
+	days  map[string]int // день (YYYY-MM-DD) -> ID цитаты
+	cycle map[int]bool   // выпавшие в текущем круге; круг кончается, когда не остается живых невыпавших. Удаленные убирает GC
+}
+
+func newDailyRotation() *dailyRotation {
+	return &dailyRotation{days: make(map[string]int), cycle: make(map[int]bool)}
+}
+
+// новый круг по журналу не записывается отдельно: повторно выпасть может только цитата прошлого круга,
+// и только когда все живые цитаты уже выпадали
+func (r *dailyRotation) apply(day string, id int) {
+	if r.cycle[id] {
+		r.cycle = make(map[int]bool)
+	}
+	r.cycle[id] = true
+	r.days[day] = id
+}
+
+// цитата дня day, если она выбрана и не удалена. Вызывается под блокировкой базы
+func (db *MemDB) dailyQuote(day string) (entities.Quote, error) {
+	id, picked := db.daily.days[day]
+	if !picked {
+		return entities.Quote{}, entities.Errorf(entities.ErrNotFound, "no quote of the day for %s", day)
+	}
+	sQuote, exists := db.quotes[id]
+	if !exists || sQuote.isDeleted() {
+		return entities.Quote{}, entities.Errorf(entities.ErrNotFound, "quote of the day for %s was deleted", day)
+	}
+	return *sQuote.Quote, nil
+}
+
+// цитата, выбранная на день day (YYYY-MM-DD), без выбора новой. Блокировка на чтение (для работы GC)
+func (db *MemDB) GetDailyQuote(ctx context.Context, day string) (entities.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Quote{}, err
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	return db.dailyQuote(day)
+}
+
+// цитата дня day; если ее еще нет или она удалена, выбирает случайную из живых, не выпадавших в этом круге,
+// и записывает выбор в журнал, поэтому после перезапуска день отдает ту же цитату
+func (db *MemDB) PickDailyQuote(ctx context.Context, day string) (entities.Quote, error) {
+	quote, err := db.GetDailyQuote(ctx, day)
+	if !errors.Is(err, entities.ErrNotFound) {
+		return quote, err
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return entities.Quote{}, err
+	}
+	// пока ждали блокировку, цитату дня мог выбрать другой запрос
+	if quote, err := db.dailyQuote(day); err == nil {
+		return quote, nil
+	}
+
+	id, ok := db.nextDailyID()
+	if !ok {
+		return entities.Quote{}, entities.Errorf(entities.ErrNotFound, "no quotes")
+	}
+	if err := db.logRecord(walRecord{Op: opDaily, Day: day, ID: id}); err != nil {
+		return entities.Quote{}, err
+	}
+	db.daily.apply(day, id)
+
+	return *db.quotes[id].Quote, nil
+}
+
+// случайная живая цитата, которой еще не было в текущем круге, а если таких нет - любая живая.
+// Вызывается под блокировкой на запись: удаления не идут, и индексы можно читать без randomMu
+func (db *MemDB) nextDailyID() (int, bool) {
+	all := db.random.statusView(attributionStatuses)
+	fresh := &liveIDs{}
+	for k := range all.count() {
+		if id := all.at(k); !db.daily.cycle[id] {
+			fresh.ids = append(fresh.ids, id)
+		}
+	}
+
+	var c candidates = fresh
+	if fresh.count() == 0 {
+		c = all
+	}
+	ids := sampleIDs(c, 1, db.rand)
+	if len(ids) == 0 {
+		return -1, false
+	}
+	return ids[0], true
+}
+
+// состояние ротации для снапшота, под блокировкой на чтение
+func (r *dailyRotation) capture() (map[string]int, []int) {
+	cycle := make([]int, 0, len(r.cycle))
+	for id := range r.cycle {
+		cycle = append(cycle, id)
+	}
+	slices.Sort(cycle)
+	return maps.Clone(r.days), cycle
+}
+
+func (r *dailyRotation) restore(days map[string]int, cycle []int) {
+	maps.Copy(r.days, days)
+	for _, id := range cycle {
+		r.cycle[id] = true
+	}
+}
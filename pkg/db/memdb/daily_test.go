@@ -0,0 +1,197 @@
+package memdb_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"slices"
+	"testing"
+	"time"
+)
+
+func day(i int) string {
+	return time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i).Format(time.DateOnly)
+}
+
+func TestDailyRotation(t *testing.T) {
+	dir := t.TempDir()
+	opts := []memdb.Option{memdb.WithWAL(filepath.Join(dir, "quotes.wal")), memdb.WithSnapshots(dir, 0)}
+	ctx := context.Background()
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	if _, err := db.PickDailyQuote(ctx, day(0)); !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("PickDailyQuote on empty DB: expected not found, got %v", err)
+	}
+	for i := range 6 {
+		_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", i), Author: "A"})
+	}
+	_ = db.DeleteQuote(ctx, 5)
+
+	// первый круг - все 5 живых цитат без повторов, второй снова без повторов
+	picked := make(map[string]int)
+	pick := func(db *memdb.MemDB, i int) int {
+		t.Helper()
+		quote, err := db.PickDailyQuote(ctx, day(i))
+		if err != nil {
+			t.Fatalf("PickDailyQuote(%s) failed: %v", day(i), err)
+		}
+		return quote.ID
+	}
+	for round := range 2 {
+		seen := make(map[int]bool)
+		for i := round * 5; i < round*5+5; i++ {
+			id := pick(db, i)
+			if seen[id] || id == 5 {
+				t.Fatalf("round %d: unexpected quote %d on %s, seen %v", round, id, day(i), seen)
+			}
+			seen[id] = true
+			picked[day(i)] = id
+			if again := pick(db, i); again != id {
+				t.Fatalf("PickDailyQuote(%s) twice: %d, then %d", day(i), id, again)
+			}
+		}
+		if round == 0 {
+			if err := db.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+	}
+
+	// удаленная цитата дня заменяется только на сегодня, прошедший день остается без цитаты
+	deleted := map[int]bool{picked[day(8)]: true, picked[day(9)]: true}
+	for id := range deleted {
+		_ = db.DeleteQuote(ctx, id)
+	}
+	for d, id := range picked {
+		if deleted[id] {
+			if _, err := db.GetDailyQuote(ctx, d); !errors.Is(err, entities.ErrNotFound) {
+				t.Fatalf("GetDailyQuote(%s) of a deleted quote: expected not found, got %v", d, err)
+			}
+			delete(picked, d)
+		}
+	}
+	today := pick(db, 9)
+	if deleted[today] {
+		t.Fatalf("deleted quote of the day was not replaced: %d", today)
+	}
+	picked[day(9)] = today
+
+	if _, err := db.GetDailyQuote(ctx, day(10)); !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("GetDailyQuote of a day without pick: expected not found, got %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// после перезапуска дни отдают те же цитаты, а круг продолжается
+	db = newTestDB(t, opts...)
+	for d, id := range picked {
+		quote, err := db.GetDailyQuote(ctx, d)
+		if err != nil || quote.ID != id {
+			t.Fatalf("GetDailyQuote(%s) after restart: expected %d, got %d, %v", d, id, quote.ID, err)
+		}
+	}
+	// второй круг кончился на замене удаленной: новый круг начался с сегодняшней цитаты
+	// и до исчерпания не повторяет ее, в том числе после перезапуска
+	seen := map[int]bool{today: true}
+	for i := 10; i < 12; i++ {
+		id := pick(db, i)
+		if seen[id] || deleted[id] || id == 5 {
+			t.Fatalf("PickDailyQuote(%s) after restart: unexpected quote %d, seen %v", day(i), id, seen)
+		}
+		seen[id] = true
+	}
+}
+
+// круг цитаты дня из последнего снапшота в dir
+func snapshotCycle(t *testing.T, dir string) []int {
+	t.Helper()
+
+	names, _ := filepath.Glob(filepath.Join(dir, "snapshot-*.snap"))
+	if len(names) == 0 {
+		t.Fatal("no snapshots")
+	}
+	slices.Sort(names)
+	data, err := os.ReadFile(names[len(names)-1])
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	var snap struct {
+		Cycle []int `json:"daily_cycle"`
+	}
+	if err := json.Unmarshal(data[4:], &snap); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	return snap.Cycle
+}
+
+func TestDailyCycleGC(t *testing.T) {
+	dir := t.TempDir()
+	opts := []memdb.Option{memdb.WithWAL(filepath.Join(dir, "quotes.wal")), memdb.WithSnapshots(dir, 0)}
+	ctx := context.Background()
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	for i := range 3 {
+		_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", i), Author: "A"})
+	}
+	var picked []int
+	for i := range 2 {
+		quote, err := db.PickDailyQuote(ctx, day(i))
+		if err != nil {
+			t.Fatalf("PickDailyQuote(%s) failed: %v", day(i), err)
+		}
+		picked = append(picked, quote.ID)
+		_ = db.DeleteQuote(ctx, quote.ID)
+	}
+
+	// GC убирает удаленные цитаты из круга, и в снапшот они больше не попадают
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := db.Snapshot(); err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		if len(snapshotCycle(t, dir)) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deleted quotes %v are still in the daily cycle: %v", picked, snapshotCycle(t, dir))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// ротация продолжается: оставшаяся цитата выпадает, потом начинается новый круг из нее же
+	live := 3 - picked[0] - picked[1]
+	for i := 2; i < 4; i++ {
+		quote, err := db.PickDailyQuote(ctx, day(i))
+		if err != nil || quote.ID != live {
+			t.Fatalf("PickDailyQuote(%s) after GC: expected %d, got %v (%v)", day(i), live, quote, err)
+		}
+	}
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if got := snapshotCycle(t, dir); fmt.Sprint(got) != fmt.Sprint([]int{live}) {
+		t.Fatalf("expected cycle [%d], got %v", live, got)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db = newTestDB(t, opts...)
+	for i := 2; i < 4; i++ {
+		if quote, err := db.GetDailyQuote(ctx, day(i)); err != nil || quote.ID != live {
+			t.Fatalf("GetDailyQuote(%s) after restart: expected %d, got %v (%v)", day(i), live, quote, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package memdb
+
+import (
+	"hash/fnv"
+	"quote_book/pkg/analysis"
+	"quote_book/pkg/entities"
+	"slices"
+	"strings"
+)
+
+// текст для сравнения цитат: без различия регистра и ё/е, слова через один пробел,
+// пунктуация и кавычки любого вида (в том числе «», “”, ’) не учитываются
+func duplicateText(text string) string {
+	return strings.Join(analysis.Folding.Terms(text), " ")
+}
+
+// отпечаток цитаты: автор и нормализованный текст; у цитат без букв и цифр отпечатка нет
+func fingerprint(authorKey, text string) (uint64, bool) {
+	normalized := duplicateText(text)
+	if normalized == "" {
+		return 0, false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(authorKey))
+	h.Write([]byte{0})
+	h.Write([]byte(normalized))
+	return h.Sum64(), true
+}
+
+// индекс отпечатков: отпечаток -> цитаты с ним, включая удаленные, но еще не собранные GC.
+// Меняется под блокировкой базы на запись
+type fingerprintIndex map[uint64][]int
+
+func (idx fingerprintIndex) add(sQuote *safeQuote) {
+	if fp, ok := fingerprint(sQuote.authorKey, sQuote.Text); ok {
+		idx[fp] = append(idx[fp], sQuote.ID)
+	}
+}
+
+func (idx fingerprintIndex) remove(sQuote *safeQuote) {
+	fp, ok := fingerprint(sQuote.authorKey, sQuote.Text)
+	if !ok {
+		return
+	}
+	ids := slices.DeleteFunc(idx[fp], func(id int) bool { return id == sQuote.ID })
+	if len(ids) == 0 {
+		delete(idx, fp)
+		return
+	}
+	idx[fp] = ids
+}
+
+// живая цитата того же автора с тем же нормализованным текстом. Совпадение отпечатков проверяется сравнением текстов,
+// поэтому коллизия хеша не дает ложного дубля. Вызывается под блокировкой на запись
+func (db *MemDB) findDuplicate(quote *entities.Quote) (int, bool) {
+	key := db.resolveAuthor(quote.Author)
+	fp, ok := fingerprint(key, quote.Text)
+	if !ok {
+		return -1, false
+	}
+	normalized := duplicateText(quote.Text)
+	for _, id := range db.fingerprints[fp] {
+		sQuote := db.quotes[id]
+		if !sQuote.deleted && sQuote.authorKey == key && duplicateText(sQuote.Text) == normalized {
+			return id, true
+		}
+	}
+	return -1, false
+}
@@ -0,0 +1,88 @@
+package memdb_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"testing"
+)
+
+func TestDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	opts := []memdb.Option{memdb.WithWAL(filepath.Join(dir, "quotes.wal")), memdb.WithSnapshots(dir, 0)}
+	ctx := context.Background()
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	duplicateOf := func(db *memdb.MemDB, quote entities.Quote, want int) {
+		t.Helper()
+		err := db.AddQuote(ctx, quote)
+		var duplicate *entities.DuplicateError
+		if !errors.As(err, &duplicate) || !errors.Is(err, entities.ErrConflict) || duplicate.ExistingID != want {
+			t.Fatalf("AddQuote(%q by %q): expected duplicate of %d, got %v", quote.Text, quote.Author, want, err)
+		}
+	}
+	added := func(db *memdb.MemDB, quote entities.Quote, opts ...entities.AddOption) {
+		t.Helper()
+		if err := db.AddQuote(ctx, quote, opts...); err != nil {
+			t.Fatalf("AddQuote(%q by %q) failed: %v", quote.Text, quote.Author, err)
+		}
+	}
+
+	added(db, entities.Quote{Text: "Жизнь — это «игра», а ёлка — дерево.", Author: "Пушкин"}) // 0
+	for _, text := range []string{
+		"жизнь это “игра” а елка дерево",
+		"  ЖИЗНЬ,   это 'игра'!  А ЁЛКА - ДЕРЕВО...",
+		"Жизнь - это \"игра\",\nа ёлка — дерево",
+	} {
+		duplicateOf(db, entities.Quote{Text: text, Author: " пушкин "}, 0)
+	}
+
+	added(db, entities.Quote{Text: "Жизнь — это игра, а ёлка — дерево.", Author: "Толстой"})                         // 1: другой автор
+	added(db, entities.Quote{Text: "Жизнь — это игра, а ёлка — куст.", Author: "Пушкин"})                            // 2: другой текст
+	added(db, entities.Quote{Text: "Жизнь — это игра, а ёлка — дерево.", Author: "Пушкин"}, entities.AllowDuplicate) // 3
+	added(db, entities.Quote{Text: "...", Author: "Пушкин"})                                                         // 4: без слов не сравнивается
+	added(db, entities.Quote{Text: "!!!", Author: "Пушкин"})                                                         // 5
+
+	// индекс следует за правкой, слиянием и удалением
+	if _, err := db.UpdateQuote(ctx, 2, func(q *entities.Quote) error {
+		q.Text = "Мороз и солнце"
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateQuote failed: %v", err)
+	}
+	duplicateOf(db, entities.Quote{Text: "Мороз и солнце!", Author: "Пушкин"}, 2)
+	added(db, entities.Quote{Text: "Жизнь — это игра, а ёлка — куст.", Author: "Пушкин"}) // 6
+
+	if _, err := db.MergeAuthors(ctx, "Толстой", "Пушкин"); err != nil {
+		t.Fatalf("MergeAuthors failed: %v", err)
+	}
+	duplicateOf(db, entities.Quote{Text: "Мороз и солнце", Author: "Толстой"}, 2)
+
+	_ = db.DeleteQuote(ctx, 2)
+	added(db, entities.Quote{Text: "Мороз и солнце", Author: "Пушкин"}) // 7
+
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Я вас любил", Author: "Пушкин"}) // 8
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db = newTestDB(t, opts...)
+	duplicateOf(db, entities.Quote{Text: "мороз и солнце", Author: "Пушкин"}, 7)
+	duplicateOf(db, entities.Quote{Text: "я вас любил", Author: "Толстой"}, 8)
+
+	// дубль остается дублем, пока жива хотя бы одна из одинаковых цитат
+	for _, id := range []int{0, 1} {
+		_ = db.DeleteQuote(ctx, id)
+	}
+	duplicateOf(db, entities.Quote{Text: "Жизнь это игра а елка дерево", Author: "Пушкин"}, 3)
+	_ = db.DeleteQuote(ctx, 3)
+	added(db, entities.Quote{Text: "Жизнь это игра а елка дерево", Author: "Пушкин"})
+}
@@ -0,0 +1,126 @@
+package memdb
+
+import "math/rand"
+
+// дерево Фенвика над позициями 0..size-1: изменение значения, префиксная сумма и поиск позиции
+// по префиксной сумме - за O(log size). Размер - степень двойки и растет удвоением
+type fenwick struct {
+	tree []int64 // tree[i] - сумма позиций (i-lowbit(i), i] при нумерации с 1
+}
+
+func (f *fenwick) size() int {
+	return max(len(f.tree)-1, 0)
+}
+
+// увеличивает размер так, чтобы в дереве была позиция n-1.
+// Узлы новой половины покрывают только новые (нулевые) позиции, кроме последнего - он покрывает все
+func (f *fenwick) grow(n int) {
+	if f.tree == nil {
+		f.tree = make([]int64, 2)
+	}
+	for size := f.size(); size < n; size *= 2 {
+		total := f.tree[size]
+		f.tree = append(f.tree, make([]int64, size)...)
+		f.tree[2*size] = total
+	}
+}
+
+// позиция должна помещаться в дерево (grow)
+func (f *fenwick) add(pos int, delta int64) {
+	for i := pos + 1; i < len(f.tree); i += i & -i {
+		f.tree[i] += delta
+	}
+}
+
+// сумма позиций [0, n)
+func (f *fenwick) prefix(n int) int64 {
+	n = min(n, f.size())
+	var sum int64
+	for i := n; i > 0; i -= i & -i {
+		sum += f.tree[i]
+	}
+	return sum
+}
+
+func (f *fenwick) total() int64 {
+	return f.prefix(f.size())
+}
+
+func (f *fenwick) find(k int64) int {
+	return findIn([]*fenwick{f}, k)
+}
+
+// наименьшая позиция, на которой префиксная сумма значений всех деревьев превышает k (0 <= k < суммы).
+// Деревья одного размера; при единичных значениях это k-я по порядку занятая позиция
+func findIn(trees []*fenwick, k int64) int {
+	size := trees[0].size()
+	pos := 0
+	for step := size; step > 0; step /= 2 {
+		next := pos + step
+		if next > size {
+			continue
+		}
+		var sum int64
+		for _, f := range trees {
+			sum += f.tree[next]
+		}
+		if sum <= k {
+			pos = next
+			k -= sum
+		}
+	}
+	return pos
+}
+
+// значение в позиции pos
+func (f *fenwick) value(pos int) int64 {
+	return f.prefix(pos+1) - f.prefix(pos)
+}
+
+// позиция, взятая из дерева без его изменения: ее значение больше не учитывается
+type takenPoint struct {
+	pos   int
+	value int64
+}
+
+// как find, но значения позиций из taken считаются нулевыми (0 <= k < суммы без них).
+// Дерево читается под блокировкой на чтение, поэтому выбор без возвращения не меняет его, а вычитает taken
+// при спуске; taken не длиннее числа выбираемых позиций, поэтому вычитается перебором
+func (f *fenwick) findWithout(k int64, taken []takenPoint) int {
+	size := f.size()
+	pos := 0
+	for step := size; step > 0; step /= 2 {
+		next := pos + step
+		if next > size {
+			continue
+		}
+		// узел next покрывает позиции [pos, next)
+		sum := f.tree[next]
+		for _, t := range taken {
+			if t.pos >= pos && t.pos < next {
+				sum -= t.value
+			}
+		}
+		if sum <= k {
+			pos = next
+			k -= sum
+		}
+	}
+	return pos
+}
+
+// n разных позиций с вероятностью, пропорциональной их значениям, - выбор без возвращения.
+// Если позиций с ненулевым значением меньше n - все такие
+func (f *fenwick) sample(n int, rng *rand.Rand) []int {
+	total := f.total()
+	taken := make([]takenPoint, 0, n)
+	positions := make([]int, 0, n)
+	for len(positions) < n && total > 0 {
+		pos := f.findWithout(rng.Int63n(total), taken)
+		value := f.value(pos)
+		taken = append(taken, takenPoint{pos: pos, value: value})
+		total -= value
+		positions = append(positions, pos)
+	}
+	return positions
+}
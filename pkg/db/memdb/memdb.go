@@ -1,144 +1,511 @@
 package memdb
 
 import (
-	"errors"
+	"context"
 	"math/rand"
+	"os"
 	"quote_book/pkg/entities"
 	"quote_book/pkg/utils"
+	"reflect"
+	"slices"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 const garbagePart = 0.1
 
+// как часто длинные проходы по базе проверяют отмену контекста
+const ctxCheckEvery = 1024
+
 type safeQuote struct {
 	*entities.Quote
-	deleted bool
+	length    int    // длина текста в символах, для фильтров и сортировки
+	authorKey string // нормализованный автор, ключ authorIndex
+	deleted   bool
 	sync.RWMutex
 }
 
+func newSafeQuote(quote entities.Quote, authorKey string) *safeQuote {
+	return &safeQuote{Quote: &quote, length: utf8.RuneCountInString(quote.Text), authorKey: authorKey}
+}
+
+// позиция в порядке выдачи; автор - ключ индекса, поэтому разные написания одного автора идут вместе
+func (sq *safeQuote) position() entities.Position {
+	return entities.Position{Author: sq.authorKey, Length: sq.length, Created: sq.CreatedAt, ID: sq.ID}
+}
+
+func (sq *safeQuote) isDeleted() bool {
+	sq.RLock()
+	defer sq.RUnlock()
+
+	return sq.deleted
+}
+
+// aliveIDs упорядочен по возрастанию ID: добавления идут под блокировкой записи с растущими ID,
+// а GC только вычеркивает удаленные
 type MemDB struct {
 	sync.RWMutex
-	idGenerator *utils.IDGenerator
-	garbagePart float64
-	quotes      map[int]*safeQuote
-	authorIndex map[string]map[int]*safeQuote //Maybe better make indexes map[string]map[string][]*entities.Quote for bigger project
-	aliveIDs    []int
-	aliveIDsMu  sync.Mutex
-	deadIDs     map[int]bool
-	deadIDsMu   sync.Mutex
+	idGenerator    *utils.IDGenerator
+	garbagePart    float64
+	quotes         map[int]*safeQuote
+	authorIndex    map[string]*authorEntry
+	authorPrefixes []authorPrefix    // упорядочен, для подсказок по началу имени
+	aliases        map[string]string // ключ псевдонима -> ключ канонического автора
+	tagIndex       map[string]*tagEntry
+	fingerprints   fingerprintIndex // для поиска дублей
+	textIndex      *textIndex
+	random         *randomIndex
+	randomMu       sync.RWMutex // индексы случайного выбора меняются и при удалении под блокировкой на чтение
+	rand           *rand.Rand
+	daily          *dailyRotation
+	bags           *shuffleBags
+	clock          func() time.Time
+	aliveIDs       []int
+	aliveIDsMu     sync.Mutex
+	deadIDs        map[int]bool
+	deadIDsMu      sync.Mutex
+	wal            *wal
+	snapshotDir    string
+	snapshotLSN    uint64
+	snapshotMu     sync.Mutex
+	done           chan struct{}
+	closeOnce      sync.Once
+	closeErr       error
+	wg             sync.WaitGroup
 }
 
-func New() *MemDB {
+// восстанавливает состояние и генератор ID из последнего снапшота и хвоста журнала, если они включены
+func New(opts ...Option) (*MemDB, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	db := &MemDB{
-		garbagePart: garbagePart,
-		quotes:      make(map[int]*safeQuote),
-		authorIndex: make(map[string]map[int]*safeQuote),
-		aliveIDs:    make([]int, 0),
-		deadIDs:     make(map[int]bool),
-		idGenerator: utils.NewIDGenerator(0),
+		garbagePart:  garbagePart,
+		quotes:       make(map[int]*safeQuote),
+		authorIndex:  make(map[string]*authorEntry),
+		aliases:      make(map[string]string),
+		tagIndex:     make(map[string]*tagEntry),
+		fingerprints: make(fingerprintIndex),
+		textIndex:    newTextIndex(o.analyzer),
+		random:       newRandomIndex(),
+		rand:         rand.New(&lockedSource{src: o.randSource}),
+		daily:        newDailyRotation(),
+		bags:         newShuffleBags(o.bagTTL, o.maxBags),
+		clock:        o.clock,
+		aliveIDs:     make([]int, 0),
+		deadIDs:      make(map[int]bool),
+		snapshotDir:  o.snapshotDir,
+		done:         make(chan struct{}),
 	}
+
+	nextID := 0
+	if o.snapshotDir != "" {
+		if err := os.MkdirAll(o.snapshotDir, 0o755); err != nil {
+			return nil, err
+		}
+		snap, err := loadLatestSnapshot(o.snapshotDir)
+		if err != nil {
+			return nil, err
+		}
+		if snap != nil {
+			db.restoreSnapshot(snap)
+			db.snapshotLSN = snap.LSN
+			nextID = snap.NextID
+		}
+	}
+
+	if o.walPath != "" {
+		l, err := openWAL(o.walPath, o.syncPolicy, o.syncInterval)
+		if err != nil {
+			return nil, err
+		}
+		err = l.replay(db.snapshotLSN, func(rec walRecord) {
+			switch rec.Op {
+			case opAdd:
+				db.applyAdd(*rec.Quote)
+				nextID = max(nextID, rec.Quote.ID+1)
+			case opUpdate:
+				db.applyUpdate(*rec.Quote)
+			case opDelete:
+				db.applyDelete(rec.ID)
+			case opMerge:
+				db.applyMerge(rec.From, rec.Into, valueOrZero(rec.At))
+			case opDaily:
+				db.daily.apply(rec.Day, rec.ID)
+			}
+		})
+		if err != nil {
+			l.close()
+			return nil, err
+		}
+		db.wal = l
+	}
+	db.idGenerator = utils.NewIDGenerator(nextID)
+
+	db.wg.Add(1)
 	go db.GarbageCollector()
 
-	return db
+	if o.snapshotDir != "" && o.snapshotInterval > 0 {
+		db.wg.Add(1)
+		go db.snapshotLoop(o.snapshotInterval)
+	}
+
+	return db, nil
 }
 
-// блокируем только добавление живых индексов - проблема только если одновременно добавляем в слайс
-func (db *MemDB) AddQuote(quote entities.Quote) error {
-	if quote.Text == "" {
-		return errors.New("blank quote")
+// останавливает сборщик мусора и закрывает журнал, повторные вызовы безопасны
+func (db *MemDB) Close() error {
+	db.closeOnce.Do(func() {
+		close(db.done)
+		db.wg.Wait()
+
+		if db.wal != nil {
+			db.closeErr = db.wal.close()
+		}
+	})
+	return db.closeErr
+}
+
+// сначала пишем в журнал, потом применяем - под блокировкой, чтобы порядок в журнале совпадал с порядком в памяти.
+// Живая цитата того же автора с тем же текстом без учета регистра, пунктуации и пробелов - дубль,
+// он отклоняется с entities.DuplicateError, если не передан entities.AllowDuplicate
+func (db *MemDB) AddQuote(ctx context.Context, quote entities.Quote, opts ...entities.AddOption) error {
+	if err := db.validateQuote(&quote); err != nil {
+		return err
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	// пока ничего не записано, отмена еще имеет смысл
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !slices.Contains(opts, entities.AllowDuplicate) {
+		if id, found := db.findDuplicate(&quote); found {
+			return &entities.DuplicateError{ExistingID: id}
+		}
 	}
 
 	quote.ID = db.idGenerator.GetID()
+	// время берется под блокировкой, чтобы порядок created_at совпадал с порядком ID, пока часы не идут назад
+	quote.CreatedAt = db.now()
+	quote.UpdatedAt = quote.CreatedAt
 
-	sQuote := &safeQuote{Quote: &quote}
+	if err := db.logRecord(walRecord{Op: opAdd, Quote: &quote}); err != nil {
+		return err
+	}
+	db.applyAdd(quote)
 
-	db.quotes[quote.ID] = sQuote
-	if db.authorIndex[quote.Author] == nil {
-		db.authorIndex[quote.Author] = make(map[int]*safeQuote)
+	return nil
+}
+
+// проверяет цитату и приводит ее к виду, в котором она хранится
+func (db *MemDB) validateQuote(quote *entities.Quote) error {
+	if quote.Text == "" {
+		return entities.Errorf(entities.ErrValidation, "blank quote")
+	}
+
+	tags, err := normalizeTags(quote.Tags)
+	if err != nil {
+		return err
+	}
+	quote.Tags = tags
+
+	source, err := normalizeSource(quote.Source, db.now().Year())
+	if err != nil {
+		return err
+	}
+	quote.Source = source
+
+	if quote.Attribution == "" {
+		quote.Attribution = entities.AttributionUnverified
+	}
+	if !quote.Attribution.Valid() {
+		return entities.Errorf(entities.ErrValidation, "unknown attribution status %q", quote.Attribution)
+	}
+
+	if quote.Weight == 0 {
+		quote.Weight = 1
+	}
+	if quote.Weight < 1 || quote.Weight > maxWeight {
+		return entities.Errorf(entities.ErrValidation, "weight must be between 1 and %d", maxWeight)
 	}
-	db.authorIndex[quote.Author][sQuote.ID] = sQuote
+	return nil
+}
+
+func (db *MemDB) applyAdd(quote entities.Quote) {
+	sQuote := newSafeQuote(quote, db.resolveAuthor(quote.Author))
+
+	db.quotes[quote.ID] = sQuote
+	db.indexAuthor(sQuote)
+	db.indexTags(sQuote)
+	db.textIndex.add(quote.ID, quote.Text)
+	db.random.add(sQuote)
+	db.fingerprints.add(sQuote)
 
 	db.aliveIDsMu.Lock()
 	db.aliveIDs = append(db.aliveIDs, quote.ID)
 	db.aliveIDsMu.Unlock()
+}
 
-	return nil
+// изменение атомарно: update получает копию цитаты под блокировкой записи, ID и удаленность не меняются
+func (db *MemDB) UpdateQuote(ctx context.Context, id int, update func(*entities.Quote) error) (entities.Quote, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return entities.Quote{}, err
+	}
+
+	sQuote, exists := db.quotes[id]
+	if !exists || sQuote.deleted {
+		return entities.Quote{}, entities.Errorf(entities.ErrNotFound, "quote %d", id)
+	}
+
+	quote := *sQuote.Quote
+	// update не должен менять теги и источник хранимой цитаты
+	quote.Tags = slices.Clone(quote.Tags)
+	if quote.Source != nil {
+		source := *quote.Source
+		quote.Source = &source
+	}
+	if err := update(&quote); err != nil {
+		return entities.Quote{}, err
+	}
+	quote.ID = id
+	if err := db.validateQuote(&quote); err != nil {
+		return entities.Quote{}, err
+	}
+	quote.CreatedAt = sQuote.CreatedAt
+	quote.UpdatedAt = sQuote.UpdatedAt
+	// изменение без изменений не пишется в журнал и не сдвигает updated_at
+	if reflect.DeepEqual(quote, *sQuote.Quote) {
+		return quote, nil
+	}
+	quote.UpdatedAt = db.now()
+
+	if err := db.logRecord(walRecord{Op: opUpdate, Quote: &quote}); err != nil {
+		return entities.Quote{}, err
+	}
+	db.applyUpdate(quote)
+
+	return quote, nil
+}
+
+// вызывается под блокировкой на запись или при проигрывании журнала
+func (db *MemDB) applyUpdate(quote entities.Quote) {
+	sQuote, exists := db.quotes[quote.ID]
+	if !exists || sQuote.deleted {
+		return
+	}
+
+	if sQuote.Text != quote.Text {
+		db.textIndex.update(quote.ID, sQuote.Text, quote.Text)
+	}
+	// смена только написания автора ключ не меняет
+	key := db.resolveAuthor(quote.Author)
+	reindexAuthor := sQuote.authorKey != key
+	refingerprint := reindexAuthor || sQuote.Text != quote.Text
+	reindexTags := !slices.Equal(sQuote.Tags, quote.Tags)
+	length := utf8.RuneCountInString(quote.Text)
+	reindexRandom := sQuote.length != length || attributionOf(sQuote.Quote) != attributionOf(&quote) ||
+		weightOf(sQuote.Quote) != weightOf(&quote)
+	if reindexAuthor {
+		db.unindexAuthor(sQuote)
+	}
+	if reindexTags {
+		db.unindexTags(sQuote)
+	}
+	if reindexRandom {
+		db.random.remove(sQuote)
+	}
+	if refingerprint {
+		db.fingerprints.remove(sQuote)
+	}
+	sQuote.Quote = &quote
+	sQuote.length = length
+	sQuote.authorKey = key
+	if reindexAuthor {
+		db.indexAuthor(sQuote)
+	}
+	if reindexTags {
+		db.indexTags(sQuote)
+	}
+	if reindexRandom {
+		db.random.add(sQuote)
+	}
+	if refingerprint {
+		db.fingerprints.add(sQuote)
+	}
+}
+
+// время для created_at и updated_at: в UTC и без монотонных показаний, чтобы совпадать с прочитанным из журнала
+func (db *MemDB) now() time.Time {
+	return db.clock().UTC().Round(0)
+}
+
+func valueOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
 }
 
 // блокировка на чтение (для работы GC)
-func (db *MemDB) GetAllQuotes() ([]entities.Quote, error) {
+func (db *MemDB) GetAllQuotes(ctx context.Context) ([]entities.Quote, error) {
 	db.RLock()
 	defer db.RUnlock()
 	quotes := make([]entities.Quote, 0, len(db.quotes))
 
+	i := 0
 	for _, sQuote := range db.quotes {
-		if !sQuote.deleted {
+		if i%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		i++
+
+		if !sQuote.isDeleted() {
 			quotes = append(quotes, *sQuote.Quote)
 		}
 	}
 	return quotes, nil
 }
 
-// блокировка на чтение (для работы GC)
-func (db *MemDB) GetRandomQuote() (entities.Quote, error) {
+// логически удаленные, но еще не собранные GC цитаты считаются отсутствующими
+func (db *MemDB) GetQuoteByID(ctx context.Context, id int) (entities.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Quote{}, err
+	}
+
 	db.RLock()
 	defer db.RUnlock()
 
-	id, err := db.GetAliveID()
-	if err != nil {
-		return entities.Quote{}, err
+	sQuote, exists := db.quotes[id]
+	if !exists {
+		return entities.Quote{}, entities.Errorf(entities.ErrNotFound, "quote %d", id)
+	}
+
+	sQuote.RLock()
+	defer sQuote.RUnlock()
+
+	if sQuote.deleted {
+		return entities.Quote{}, entities.Errorf(entities.ErrNotFound, "quote %d", id)
 	}
-	return *db.quotes[id].Quote, nil
+	return *sQuote.Quote, nil
 }
 
+// ID случайной живой цитаты; выбор по индексу живых цитат, без повторных попыток
 func (db *MemDB) GetAliveID() (int, error) {
 	db.RLock()
 	defer db.RUnlock()
-	id := 0
-	for {
-		if len(db.aliveIDs)-len(db.deadIDs) == 0 {
-			return -1, errors.New("no valid ids")
-		}
-		id = db.aliveIDs[rand.Intn(len(db.aliveIDs))]
-		if !db.quotes[id].deleted {
-			return id, nil
-		}
+	db.randomMu.RLock()
+	defer db.randomMu.RUnlock()
+
+	ids := sampleIDs(db.random.statusView(attributionStatuses), 1, db.rand)
+	if len(ids) == 0 {
+		return -1, entities.Errorf(entities.ErrNotFound, "no quotes")
 	}
+	return ids[0], nil
 }
 
 // логическое удаление, чтобы не останавливать всю базу ради одного удаления
-func (db *MemDB) DeleteQuote(id int) error {
+func (db *MemDB) DeleteQuote(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db.RLock()
 	defer db.RUnlock()
 
-	_, exists := db.quotes[id]
+	sQuote, exists := db.quotes[id]
 	if !exists {
-		return nil
+		return entities.Errorf(entities.ErrNotFound, "quote %d", id)
 	}
 
-	db.quotes[id].Lock()
-	if !db.quotes[id].deleted {
-		db.quotes[id].deleted = true
+	sQuote.Lock()
+	defer sQuote.Unlock()
 
-		db.deadIDsMu.Lock()
-		db.deadIDs[id] = true
-		db.deadIDsMu.Unlock()
+	if sQuote.deleted {
+		return entities.Errorf(entities.ErrNotFound, "quote %d", id)
 	}
+	if err := db.logRecord(walRecord{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+	db.markDeleted(sQuote)
 
-	db.quotes[id].Unlock()
+	return nil
+}
 
+// без журнала ничего не пишет; ошибка записи означает, что хранилище не может принять изменение
+func (db *MemDB) logRecord(rec walRecord) error {
+	if db.wal == nil {
+		return nil
+	}
+	if err := db.wal.append(rec); err != nil {
+		return entities.Errorf(entities.ErrUnavailable, "wal append: %v", err)
+	}
 	return nil
 }
 
-// блокировка на чтение (для работы GC)
-func (db *MemDB) GetAuthorQuotes(author string) ([]entities.Quote, error) {
+// используется при проигрывании журнала, блокировки не нужны
+func (db *MemDB) applyDelete(id int) {
+	sQuote, exists := db.quotes[id]
+	if !exists || sQuote.deleted {
+		return
+	}
+	db.markDeleted(sQuote)
+}
+
+func (db *MemDB) markDeleted(sQuote *safeQuote) {
+	sQuote.deleted = true
+	db.textIndex.markDeleted(sQuote.ID)
+	db.authorIndex[sQuote.authorKey].live.Add(-1)
+	for _, tag := range sQuote.Tags {
+		db.tagIndex[tag].live.Add(-1)
+	}
+
+	db.randomMu.Lock()
+	db.random.remove(sQuote)
+	db.random.removeAuthorID(db.authorIndex[sQuote.authorKey], sQuote.ID)
+	for _, tag := range sQuote.Tags {
+		db.tagIndex[tag].ids.remove(sQuote.ID)
+	}
+	db.randomMu.Unlock()
+
+	db.deadIDsMu.Lock()
+	db.deadIDs[sQuote.ID] = true
+	db.deadIDsMu.Unlock()
+}
+
+// автор ищется по нормализованному ключу, удаленные до GC цитаты пропускаются.
+// Блокировка на чтение (для работы GC)
+func (db *MemDB) GetAuthorQuotes(ctx context.Context, author string) ([]entities.Quote, error) {
 	db.RLock()
 	defer db.RUnlock()
 
-	quotes := make([]entities.Quote, 0, len(db.authorIndex[author]))
-	for _, sQuote := range db.authorIndex[author] {
-		quotes = append(quotes, *sQuote.Quote)
+	entry := db.authorIndex[db.resolveAuthor(author)]
+	if entry == nil {
+		return []entities.Quote{}, nil
+	}
+	quotes := make([]entities.Quote, 0, entry.live.Load())
+	i := 0
+	for _, sQuote := range entry.quotes {
+		if i%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		i++
+
+		if !sQuote.isDeleted() {
+			quotes = append(quotes, *sQuote.Quote)
+		}
 	}
 
 	return quotes, nil
@@ -146,27 +513,44 @@ func (db *MemDB) GetAuthorQuotes(author string) ([]entities.Quote, error) {
 
 // убираем мусор, когда его больше, чем заданный порог
 func (db *MemDB) GarbageCollector() {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(time.Millisecond * 500)
+	defer ticker.Stop()
 	for {
-		if float64(len(db.deadIDs))/float64(len(db.quotes)) > db.garbagePart {
+		select {
+		case <-db.done:
+			return
+		case <-ticker.C:
+		}
+
+		db.RLock()
+		db.deadIDsMu.Lock()
+		needCollect := float64(len(db.deadIDs))/float64(len(db.quotes)) > db.garbagePart
+		db.deadIDsMu.Unlock()
+		db.RUnlock()
+
+		if needCollect {
 			db.Lock() //stop the world
 
 			for id := range db.deadIDs {
-				delete(db.authorIndex[db.quotes[id].Author], id)
+				db.textIndex.prune(id, db.quotes[id].Text)
+				db.fingerprints.remove(db.quotes[id])
+				db.unindexAuthor(db.quotes[id])
+				db.unindexTags(db.quotes[id])
+				delete(db.daily.cycle, id)
 				delete(db.quotes, id)
 			}
-			for author := range db.authorIndex {
-				if len(db.authorIndex[author]) == 0 {
-					delete(db.authorIndex, author)
-				}
-			}
 			db.deadIDs = make(map[int]bool)
 
-			db.aliveIDs = make([]int, 0, len(db.quotes))
-			for id := range db.quotes {
-				db.aliveIDs = append(db.aliveIDs, id)
+			aliveIDs := make([]int, 0, len(db.quotes))
+			for _, id := range db.aliveIDs {
+				if _, ok := db.quotes[id]; ok {
+					aliveIDs = append(aliveIDs, id)
+				}
 			}
+			db.aliveIDs = aliveIDs
 			db.Unlock()
 		}
-		time.Sleep(time.Millisecond * 500)
 	}
 }
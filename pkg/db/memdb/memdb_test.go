@@ -1,33 +1,51 @@
 package memdb_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
 	"quote_book/pkg/db/memdb"
 	"quote_book/pkg/entities"
+	"strconv"
 	"testing"
+	"time"
 )
 
+func newTestDB(t *testing.T, opts ...memdb.Option) *memdb.MemDB {
+	t.Helper()
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
 func TestAddQuote(t *testing.T) {
-	db := memdb.New()
+	db := newTestDB(t)
 
 	// Добавляем валидную цитату
 	q := entities.Quote{Text: "Hello", Author: "Author"}
-	err := db.AddQuote(q)
+	err := db.AddQuote(context.Background(), q)
 	if err != nil {
 		t.Fatalf("AddQuote failed: %v", err)
 	}
 
-	// Добавляем пустую цитату — должна быть ошибка
-	err = db.AddQuote(entities.Quote{Text: ""})
-	if err == nil {
-		t.Fatal("AddQuote with empty text should return error")
+	// Добавляем пустую цитату — должна быть ошибка валидации
+	err = db.AddQuote(context.Background(), entities.Quote{Text: ""})
+	if !errors.Is(err, entities.ErrValidation) {
+		t.Fatalf("AddQuote with empty text: expected ErrValidation, got %v", err)
 	}
 }
 
 func TestGetAllQuotes(t *testing.T) {
-	db := memdb.New()
+	db := newTestDB(t)
 
 	// Должно быть пусто изначально
-	quotes, err := db.GetAllQuotes()
+	quotes, err := db.GetAllQuotes(context.Background())
 	if err != nil {
 		t.Fatalf("GetAllQuotes failed: %v", err)
 	}
@@ -36,8 +54,8 @@ func TestGetAllQuotes(t *testing.T) {
 	}
 
 	// Добавляем цитату и проверяем
-	_ = db.AddQuote(entities.Quote{Text: "Q1", Author: "A1"})
-	quotes, err = db.GetAllQuotes()
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
+	quotes, err = db.GetAllQuotes(context.Background())
 	if err != nil {
 		t.Fatalf("GetAllQuotes failed: %v", err)
 	}
@@ -47,18 +65,18 @@ func TestGetAllQuotes(t *testing.T) {
 }
 
 func TestGetRandomQuote(t *testing.T) {
-	db := memdb.New()
+	db := newTestDB(t)
 
 	// Пустая база — ожидаем ошибку
-	_, err := db.GetRandomQuote()
+	_, err := db.GetRandomQuote(context.Background(), entities.RandomQuery{})
 	if err == nil {
 		t.Fatal("GetRandomQuote on empty DB should return error")
 	}
 
 	// Добавляем цитату
-	_ = db.AddQuote(entities.Quote{Text: "Q1", Author: "A1"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
 
-	q, err := db.GetRandomQuote()
+	q, err := db.GetRandomQuote(context.Background(), entities.RandomQuery{})
 	if err != nil {
 		t.Fatalf("GetRandomQuote failed: %v", err)
 	}
@@ -68,29 +86,33 @@ func TestGetRandomQuote(t *testing.T) {
 }
 
 func TestDeleteQuote(t *testing.T) {
-	db := memdb.New()
+	db := newTestDB(t)
 
-	_ = db.AddQuote(entities.Quote{Text: "Q1", Author: "A1"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
 
-	quotes, _ := db.GetAllQuotes()
+	quotes, _ := db.GetAllQuotes(context.Background())
 	if len(quotes) == 0 {
 		t.Fatal("no quotes to delete")
 	}
 	id := quotes[0].ID
 
-	err := db.DeleteQuote(id)
+	err := db.DeleteQuote(context.Background(), id)
 	if err != nil {
 		t.Fatalf("DeleteQuote failed: %v", err)
 	}
 
-	// Удаление несуществующего id — не ошибка
-	err = db.DeleteQuote(9999)
-	if err != nil {
-		t.Fatalf("DeleteQuote non-existent id should not error: %v", err)
+	// Удаление несуществующего или уже удаленного id — ErrNotFound
+	err = db.DeleteQuote(context.Background(), 9999)
+	if !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("DeleteQuote non-existent id: expected ErrNotFound, got %v", err)
+	}
+	err = db.DeleteQuote(context.Background(), id)
+	if !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("DeleteQuote deleted id: expected ErrNotFound, got %v", err)
 	}
 
 	// Проверяем, что цитата помечена как удалённая (не возвращается)
-	quotes, _ = db.GetAllQuotes()
+	quotes, _ = db.GetAllQuotes(context.Background())
 	for _, q := range quotes {
 		if q.ID == id {
 			t.Fatal("Deleted quote still present in GetAllQuotes")
@@ -99,13 +121,13 @@ func TestDeleteQuote(t *testing.T) {
 }
 
 func TestGetAuthorQuotes(t *testing.T) {
-	db := memdb.New()
+	db := newTestDB(t)
 
-	_ = db.AddQuote(entities.Quote{Text: "Q1", Author: "Author1"})
-	_ = db.AddQuote(entities.Quote{Text: "Q2", Author: "Author1"})
-	_ = db.AddQuote(entities.Quote{Text: "Q3", Author: "Author2"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "Author1"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q2", Author: "Author1"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q3", Author: "Author2"})
 
-	quotes, err := db.GetAuthorQuotes("Author1")
+	quotes, err := db.GetAuthorQuotes(context.Background(), "Author1")
 	if err != nil {
 		t.Fatalf("GetAuthorQuotes failed: %v", err)
 	}
@@ -113,7 +135,7 @@ func TestGetAuthorQuotes(t *testing.T) {
 		t.Fatalf("GetAuthorQuotes expected 2 quotes, got %d", len(quotes))
 	}
 
-	quotes, err = db.GetAuthorQuotes("Unknown")
+	quotes, err = db.GetAuthorQuotes(context.Background(), "Unknown")
 	if err != nil {
 		t.Fatalf("GetAuthorQuotes failed: %v", err)
 	}
@@ -121,3 +143,295 @@ func TestGetAuthorQuotes(t *testing.T) {
 		t.Fatal("GetAuthorQuotes for unknown author should return empty slice")
 	}
 }
+
+func TestGetAuthorQuotesNormalized(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q1", Author: "Confucius"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q2", Author: "  confucius "})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q3", Author: "Фёдор  Достоевский"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q4", Author: "Confucius"})
+	_ = db.DeleteQuote(ctx, 3)
+
+	quotes, err := db.GetAuthorQuotes(ctx, "CONFUCIUS")
+	if err != nil {
+		t.Fatalf("GetAuthorQuotes failed: %v", err)
+	}
+	if len(quotes) != 2 {
+		t.Fatalf("GetAuthorQuotes expected 2 live quotes, got %v", quotes)
+	}
+	for _, q := range quotes {
+		if q.ID == 0 && q.Author != "Confucius" {
+			t.Errorf("display spelling changed: got %q", q.Author)
+		}
+	}
+
+	quotes, _ = db.GetAuthorQuotes(ctx, "федор достоевский")
+	if len(quotes) != 1 || quotes[0].Author != "Фёдор  Достоевский" {
+		t.Fatalf("GetAuthorQuotes expected the original spelling, got %v", quotes)
+	}
+
+	// Исправление только написания оставляет цитату у того же автора
+	_, _ = db.UpdateQuote(ctx, 2, func(q *entities.Quote) error {
+		q.Author = "Фёдор Михайлович Достоевский"
+		return nil
+	})
+	if quotes, _ = db.GetAuthorQuotes(ctx, "Фёдор Достоевский"); len(quotes) != 0 {
+		t.Fatalf("after author change: old author still has %v", quotes)
+	}
+	page, _, _ := db.ListQuotes(ctx, entities.QuoteQuery{Author: "ФЁДОР МИХАЙЛОВИЧ ДОСТОЕВСКИЙ"}, nil, 10)
+	if len(page) != 1 || page[0].ID != 2 {
+		t.Fatalf("ListQuotes by normalized author: expected [2], got %v", page)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	db := newTestDB(t)
+
+	for i := 0; i < 2000; i++ {
+		_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q", Author: "A1"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.GetAllQuotes(ctx); err != context.Canceled {
+		t.Fatalf("GetAllQuotes with canceled context: expected %v, got %v", context.Canceled, err)
+	}
+	if _, err := db.GetAuthorQuotes(ctx, "A1"); err != context.Canceled {
+		t.Fatalf("GetAuthorQuotes with canceled context: expected %v, got %v", context.Canceled, err)
+	}
+	if err := db.AddQuote(ctx, entities.Quote{Text: "Q", Author: "A1"}); err != context.Canceled {
+		t.Fatalf("AddQuote with canceled context: expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestUpdateQuote(t *testing.T) {
+	db := newTestDB(t)
+
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
+
+	updated, err := db.UpdateQuote(context.Background(), 0, func(q *entities.Quote) error {
+		q.Author = "A2"
+		q.Text = "Q1 fixed"
+		q.ID = 42 // ID не меняется
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateQuote failed: %v", err)
+	}
+	if updated.ID != 0 || updated.Author != "A2" || updated.Text != "Q1 fixed" {
+		t.Fatalf("UpdateQuote returned wrong quote: %v", updated)
+	}
+
+	// Индекс авторов следует за сменой автора
+	quotes, _ := db.GetAuthorQuotes(context.Background(), "A1")
+	if len(quotes) != 0 {
+		t.Fatalf("expected no quotes for old author, got %d", len(quotes))
+	}
+	quotes, _ = db.GetAuthorQuotes(context.Background(), "A2")
+	if len(quotes) != 1 || quotes[0].Text != "Q1 fixed" {
+		t.Fatalf("expected updated quote for new author, got %v", quotes)
+	}
+
+	_, err = db.UpdateQuote(context.Background(), 0, func(q *entities.Quote) error {
+		q.Text = ""
+		return nil
+	})
+	if err == nil {
+		t.Fatal("UpdateQuote with blank text should return error")
+	}
+
+	_ = db.DeleteQuote(context.Background(), 0)
+	_, err = db.UpdateQuote(context.Background(), 0, func(q *entities.Quote) error { return nil })
+	if err == nil {
+		t.Fatal("UpdateQuote on deleted quote should return error")
+	}
+}
+
+func TestGetQuoteByID(t *testing.T) {
+	db := newTestDB(t)
+
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
+
+	q, err := db.GetQuoteByID(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("GetQuoteByID failed: %v", err)
+	}
+	if q.Text != "Q1" || q.Author != "A1" {
+		t.Fatalf("GetQuoteByID returned wrong quote: %v", q)
+	}
+
+	if _, err := db.GetQuoteByID(context.Background(), 9999); !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("GetQuoteByID unknown id: expected ErrNotFound, got %v", err)
+	}
+
+	_ = db.DeleteQuote(context.Background(), 0)
+	if _, err := db.GetQuoteByID(context.Background(), 0); !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("GetQuoteByID deleted id: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestListQuotes(t *testing.T) {
+	db := newTestDB(t)
+
+	for i := 0; i < 5; i++ {
+		_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q" + strconv.Itoa(i), Author: "A" + strconv.Itoa(i%2)})
+	}
+	_ = db.DeleteQuote(context.Background(), 1)
+
+	quotes, more, err := db.ListQuotes(context.Background(), entities.QuoteQuery{}, nil, 2)
+	if err != nil {
+		t.Fatalf("ListQuotes failed: %v", err)
+	}
+	if len(quotes) != 2 || quotes[0].ID != 0 || quotes[1].ID != 2 || !more {
+		t.Fatalf("ListQuotes first page: unexpected %v, more=%v", quotes, more)
+	}
+
+	quotes, more, _ = db.ListQuotes(context.Background(), entities.QuoteQuery{}, &entities.Position{ID: 2}, 2)
+	if len(quotes) != 2 || quotes[0].ID != 3 || quotes[1].ID != 4 || more {
+		t.Fatalf("ListQuotes last page: unexpected %v, more=%v", quotes, more)
+	}
+
+	quotes, more, _ = db.ListQuotes(context.Background(), entities.QuoteQuery{Author: "A0", Desc: true}, nil, 10)
+	if len(quotes) != 3 || quotes[0].ID != 4 || quotes[2].ID != 0 || more {
+		t.Fatalf("ListQuotes by author desc: unexpected %v, more=%v", quotes, more)
+	}
+
+	minID, maxID := 2, 3
+	quotes, _, _ = db.ListQuotes(context.Background(), entities.QuoteQuery{MinID: &minID, MaxID: &maxID}, nil, 10)
+	if len(quotes) != 2 || quotes[0].ID != 2 || quotes[1].ID != 3 {
+		t.Fatalf("ListQuotes by id range: unexpected %v", quotes)
+	}
+
+	maxID = math.MaxInt
+	quotes, _, _ = db.ListQuotes(context.Background(), entities.QuoteQuery{MaxID: &maxID}, nil, 10)
+	if len(quotes) != 4 {
+		t.Fatalf("ListQuotes with max_id=MaxInt: expected all 4 quotes, got %v", quotes)
+	}
+}
+
+func TestListQuotesSorted(t *testing.T) {
+	db := newTestDB(t)
+
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "ccc", Author: "B"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "a", Author: "C"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "ёёёё", Author: "A"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "bb", Author: "B"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "d", Author: "b"})
+
+	ids := func(quotes []entities.Quote) []int {
+		res := make([]int, 0, len(quotes))
+		for _, q := range quotes {
+			res = append(res, q.ID)
+		}
+		return res
+	}
+
+	// По автору без учета регистра, ID при равенстве; постранично через позицию последней цитаты
+	q := entities.QuoteQuery{SortBy: entities.SortByAuthor}
+	first, more, _ := db.ListQuotes(context.Background(), q, nil, 2)
+	last := first[len(first)-1]
+	second, _, _ := db.ListQuotes(context.Background(), q, &entities.Position{Author: last.Author, ID: last.ID}, 2)
+	last = second[len(second)-1]
+	rest, _, _ := db.ListQuotes(context.Background(), q, &entities.Position{Author: last.Author, ID: last.ID}, 2)
+	if got := fmt.Sprint(ids(first), ids(second), ids(rest)); got != "[2 0] [3 4] [1]" || !more {
+		t.Fatalf("ListQuotes by author: unexpected %s", got)
+	}
+
+	// Длина в символах, а не в байтах
+	minLen := 2
+	q = entities.QuoteQuery{SortBy: entities.SortByLength, Desc: true, MinLength: &minLen}
+	quotes, _, _ := db.ListQuotes(context.Background(), q, nil, 10)
+	if got := fmt.Sprint(ids(quotes)); got != "[2 0 3]" {
+		t.Fatalf("ListQuotes by length desc: unexpected %s", got)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.wal")
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	db, err := memdb.New(memdb.WithWAL(path), memdb.WithClock(clock))
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	// присланное время не учитывается
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q0", Author: "A", CreatedAt: at(-100)})
+	now = at(10)
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q1", Author: "B"})
+	now = at(5) // часы перевели назад
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q2", Author: "B"})
+
+	now = at(20)
+	updated, err := db.UpdateQuote(ctx, 0, func(q *entities.Quote) error {
+		q.Text = "Q0 fixed"
+		q.CreatedAt = at(-100)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateQuote failed: %v", err)
+	}
+	if !updated.CreatedAt.Equal(start) || !updated.UpdatedAt.Equal(at(20)) {
+		t.Fatalf("UpdateQuote: unexpected times %v / %v", updated.CreatedAt, updated.UpdatedAt)
+	}
+
+	// изменение, которое ничего не меняет, не сдвигает updated_at
+	now = at(30)
+	unchanged, _ := db.UpdateQuote(ctx, 0, func(q *entities.Quote) error { return nil })
+	if !unchanged.UpdatedAt.Equal(at(20)) {
+		t.Fatalf("no-op UpdateQuote moved updated_at to %v", unchanged.UpdatedAt)
+	}
+
+	now = at(40)
+	if _, err := db.MergeAuthors(ctx, "B", "A"); err != nil {
+		t.Fatalf("MergeAuthors failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	check := func(db *memdb.MemDB) {
+		t.Helper()
+
+		want := map[int][2]time.Time{
+			0: {start, at(20)},
+			1: {at(10), at(40)},
+			2: {at(5), at(40)},
+		}
+		for id, times := range want {
+			quote, err := db.GetQuoteByID(ctx, id)
+			if err != nil {
+				t.Fatalf("GetQuoteByID(%d) failed: %v", id, err)
+			}
+			if !quote.CreatedAt.Equal(times[0]) || !quote.UpdatedAt.Equal(times[1]) {
+				t.Errorf("quote %d: expected %v / %v, got %v / %v", id, times[0], times[1], quote.CreatedAt, quote.UpdatedAt)
+			}
+		}
+
+		after, before := at(5), at(10)
+		cases := []struct {
+			q    entities.QuoteQuery
+			want []int
+		}{
+			{entities.QuoteQuery{SortBy: entities.SortByCreated}, []int{0, 2, 1}},
+			{entities.QuoteQuery{SortBy: entities.SortByCreated, Desc: true}, []int{1, 2, 0}},
+			{entities.QuoteQuery{CreatedAfter: &after}, []int{1, 2}},
+			{entities.QuoteQuery{CreatedBefore: &before}, []int{0, 2}},
+			{entities.QuoteQuery{CreatedAfter: &after, CreatedBefore: &before}, []int{2}},
+		}
+		for _, c := range cases {
+			if got := listIDs(t, db, c.q); fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("ListQuotes(%+v): expected %v, got %v", c.q, c.want, got)
+			}
+		}
+	}
+
+	check(newTestDB(t, memdb.WithWAL(path)))
+}
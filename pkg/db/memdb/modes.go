@@ -0,0 +1,115 @@
+package memdb
+
+import (
+	"math/rand"
+	"quote_book/pkg/entities"
+	"slices"
+)
+
+const maxWeight = 1000
+
+// вес цитаты; у цитат, записанных до появления весов, его нет - они весят 1
+func weightOf(quote *entities.Quote) int {
+	if quote.Weight == 0 {
+		return 1
+	}
+	return quote.Weight
+}
+
+// n разных цитат с вероятностью, пропорциональной весу. Без фильтров - сразу из дерева весов по ID,
+// с фильтрами - из дерева весов, собранного по кандидатам. Вызывается под блокировкой на чтение и randomMu на чтение
+func (db *MemDB) sampleWeighted(c candidates, indexed bool, n int, rng *rand.Rand) []int {
+	if indexed {
+		return db.random.weights.sample(n, rng)
+	}
+
+	ordered := inIDOrder(c)
+	weights := &fenwick{}
+	weights.grow(ordered.count())
+	for k := range ordered.count() {
+		weights.add(k, int64(weightOf(db.quotes[ordered.at(k)].Quote)))
+	}
+	ids := weights.sample(n, rng)
+	for i, k := range ids {
+		ids[i] = ordered.at(k)
+	}
+	return ids
+}
+
+// n разных цитат: каждый раз равновероятно выбирается автор, у которого еще остались цитаты, потом его цитата.
+// Без фильтров авторы берутся из дерева слотов, с фильтрами - группируются по кандидатам.
+// Вызывается под блокировкой на чтение и randomMu на чтение
+func (db *MemDB) sampleByAuthor(c candidates, indexed bool, n int, rng *rand.Rand) []int {
+	if !indexed {
+		return db.sampleAuthorGroups(inIDOrder(c), n, rng)
+	}
+
+	ri := db.random
+	left := ri.authors.total()
+	var exhausted []takenPoint
+	picked := make(map[*authorEntry][]int) // номера выбранных цитат автора среди его живых, по возрастанию
+
+	ids := make([]int, 0, n)
+	for len(ids) < n && left > 0 {
+		entry := ri.authorSlots[ri.authors.findWithout(rng.Int63n(left), exhausted)]
+		ranks := picked[entry]
+		k := skipRanks(rng.Intn(entry.ids.count()-len(ranks)), ranks)
+		i, _ := slices.BinarySearch(ranks, k)
+		picked[entry] = slices.Insert(ranks, i, k)
+		ids = append(ids, entry.ids.at(k))
+
+		if len(picked[entry]) == entry.ids.count() {
+			exhausted = append(exhausted, takenPoint{pos: entry.slot, value: 1})
+			left--
+		}
+	}
+	return ids
+}
+
+// выбор автора, потом цитаты по кандидатам в порядке ID: группы авторов упорядочены по ключу автора,
+// поэтому с seed выбор зависит только от данных, а не от истории слотов
+func (db *MemDB) sampleAuthorGroups(c orderedCandidates, n int, rng *rand.Rand) []int {
+	byAuthor := make(map[string][]int)
+	for k := range c.count() {
+		id := c.at(k)
+		key := db.quotes[id].authorKey
+		byAuthor[key] = append(byAuthor[key], id)
+	}
+	keys := make([]string, 0, len(byAuthor))
+	for key := range byAuthor {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	groups := make([][]int, 0, len(keys))
+	for _, key := range keys {
+		groups = append(groups, byAuthor[key])
+	}
+
+	// выбранная цитата и опустевший автор заменяются последними, чтобы не выбираться повторно
+	ids := make([]int, 0, min(n, c.count()))
+	for len(ids) < n && len(groups) > 0 {
+		g := rng.Intn(len(groups))
+		group := groups[g]
+		k := rng.Intn(len(group))
+		ids = append(ids, group[k])
+
+		group[k] = group[len(group)-1]
+		groups[g] = group[:len(group)-1]
+		if len(groups[g]) == 0 {
+			groups[g] = groups[len(groups)-1]
+			groups = groups[:len(groups)-1]
+		}
+	}
+	return ids
+}
+
+// номер k-го элемента, не входящего в skipped, среди всех элементов; skipped - упорядоченные номера пропускаемых
+func skipRanks(k int, skipped []int) int {
+	for _, r := range skipped {
+		if r > k {
+			break
+		}
+		k++
+	}
+	return k
+}
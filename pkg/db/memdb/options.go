@@ -0,0 +1,118 @@
+package memdb
+
+import (
+	"math/rand"
+	"quote_book/pkg/analysis"
+	"time"
+)
+
+const (
+	defaultSyncInterval = time.Second
+	defaultBagTTL       = 30 * time.Minute
+	defaultMaxBags      = 100_000
+)
+
+type options struct {
+	walPath          string
+	syncPolicy       SyncPolicy
+	syncInterval     time.Duration
+	snapshotDir      string
+	snapshotInterval time.Duration
+	analyzer         analysis.Selector
+	clock            func() time.Time
+	randSource       rand.Source
+	bagTTL           time.Duration
+	maxBags          int
+}
+
+type Option func(*options)
+
+// WithWAL включает журнал упреждающей записи (write-ahead log) по указанному пути.
+// Пустой путь оставляет базу полностью в памяти.
+func WithWAL(path string) Option {
+	return func(o *options) {
+		o.walPath = path
+	}
+}
+
+// WithSyncPolicy задает, когда журнал сбрасывается на диск через fsync.
+// interval используется только для SyncInterval.
+func WithSyncPolicy(policy SyncPolicy, interval time.Duration) Option {
+	return func(o *options) {
+		o.syncPolicy = policy
+		if interval > 0 {
+			o.syncInterval = interval
+		}
+	}
+}
+
+// WithSnapshots включает периодические снапшоты в каталог dir.
+// При interval <= 0 снапшоты снимаются только вызовом Snapshot.
+func WithSnapshots(dir string, interval time.Duration) Option {
+	return func(o *options) {
+		o.snapshotDir = dir
+		o.snapshotInterval = interval
+	}
+}
+
+// WithAnalyzer задает, каким анализатором разбирать текст цитат и поисковых запросов.
+// По умолчанию анализатор выбирается по алфавиту текста (analysis.ByScript).
+func WithAnalyzer(selector analysis.Selector) Option {
+	return func(o *options) {
+		if selector != nil {
+			o.analyzer = selector
+		}
+	}
+}
+
+// WithClock задает часы, по которым ставятся created_at и updated_at цитат.
+// По умолчанию - time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		if now != nil {
+			o.clock = now
+		}
+	}
+}
+
+// WithRandSource задает источник случайности для выбора случайных цитат без seed.
+// Источник используется под мьютексом. По умолчанию - источник, засеянный текущим временем.
+func WithRandSource(src rand.Source) Option {
+	return func(o *options) {
+		if src != nil {
+			o.randSource = src
+		}
+	}
+}
+
+// WithBagTTL задает, через сколько времени без запросов мешок клиента (RandomQuery.Bag) забывается
+// и клиент начинает новый круг. По умолчанию - 30 минут.
+func WithBagTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl > 0 {
+			o.bagTTL = ttl
+		}
+	}
+}
+
+// WithMaxBags ограничивает число мешков клиентов в памяти: сверх него забываются давно не использованные.
+// По умолчанию - 100 000.
+func WithMaxBags(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxBags = n
+		}
+	}
+}
+
+func defaultOptions() options {
+	return options{
+		syncPolicy:   SyncAlways,
+		syncInterval: defaultSyncInterval,
+		analyzer:     analysis.ByScript,
+		clock:        time.Now,
+		randSource:   rand.NewSource(time.Now().UnixNano()),
+		bagTTL:       defaultBagTTL,
+		maxBags:      defaultMaxBags,
+	}
+}
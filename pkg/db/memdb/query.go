@@ -0,0 +1,206 @@
+package memdb
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"quote_book/pkg/entities"
+	"slices"
+	"sort"
+)
+
+// до limit цитат по запросу q, начиная после позиции after (nil - с начала); more - есть ли цитаты дальше.
+// Копируются только цитаты страницы: для порядка по ID идем по упорядоченному aliveIDs с ранней остановкой,
+// для остальных порядков отбираем limit+1 лучших кучей, не сортируя всю выборку.
+func (db *MemDB) ListQuotes(ctx context.Context, q entities.QuoteQuery, after *entities.Position, limit int) ([]entities.Quote, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	ids := db.aliveIDs
+	if q.Author != "" {
+		ids = db.authorIDs(q.Author)
+	}
+	if len(q.Tags) > 0 {
+		tagged := db.tagIDs(q.Tags, q.TagMode)
+		if q.Author != "" {
+			tagged = intersectIDs(ids, tagged)
+		}
+		ids = tagged
+	}
+	if q.MinID != nil {
+		ids = ids[sort.SearchInts(ids, *q.MinID):]
+	}
+	if q.MaxID != nil {
+		// без MaxID+1: при math.MaxInt он переполняется
+		ids = ids[:sort.Search(len(ids), func(i int) bool { return ids[i] > *q.MaxID })]
+	}
+
+	var page []*safeQuote
+	var err error
+	switch q.SortBy {
+	// created_at обычно растет вместе с ID, но после перевода часов назад порядки расходятся
+	case entities.SortByAuthor, entities.SortByLength, entities.SortByCreated:
+		page, err = db.topQuotes(ctx, ids, q, after, limit+1)
+	default:
+		page, err = db.walkQuotes(ctx, ids, q, after, limit+1)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	more := len(page) > limit
+	if more {
+		page = page[:limit]
+	}
+	quotes := make([]entities.Quote, 0, len(page))
+	for _, sQuote := range page {
+		quotes = append(quotes, *sQuote.Quote)
+	}
+	return quotes, more, nil
+}
+
+// порядок по ID совпадает с порядком ids, поэтому идем от курсора и останавливаемся на n-й подходящей
+func (db *MemDB) walkQuotes(ctx context.Context, ids []int, q entities.QuoteQuery, after *entities.Position, n int) ([]*safeQuote, error) {
+	start, end, step := 0, len(ids), 1
+	if q.Desc {
+		start, end, step = len(ids)-1, -1, -1
+	}
+	if after != nil {
+		if q.Desc {
+			start = sort.SearchInts(ids, after.ID) - 1
+		} else {
+			start = sort.SearchInts(ids, after.ID+1)
+		}
+	}
+
+	page := make([]*safeQuote, 0, min(n, len(ids)))
+	for i, checked := start, 1; i != end && len(page) < n; i, checked = i+step, checked+1 {
+		if checked%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		sQuote := db.quotes[ids[i]]
+		if db.matches(sQuote, q) {
+			page = append(page, sQuote)
+		}
+	}
+	return page, nil
+}
+
+// n первых по порядку запроса цитат после курсора; в куче держим не больше n кандидатов
+func (db *MemDB) topQuotes(ctx context.Context, ids []int, q entities.QuoteQuery, after *entities.Position, n int) ([]*safeQuote, error) {
+	top := &quoteHeap{compare: positionCompare(q)}
+	if after != nil {
+		// в курсоре автор как в цитате, сравниваем по ключу
+		pos := *after
+		pos.Author = db.resolveAuthor(pos.Author)
+		after = &pos
+	}
+	for i, id := range ids {
+		if (i+1)%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		sQuote := db.quotes[id]
+		if !db.matches(sQuote, q) {
+			continue
+		}
+		if after != nil && top.compare(sQuote.position(), *after) <= 0 {
+			continue
+		}
+
+		heap.Push(top, sQuote)
+		if top.Len() > n {
+			heap.Pop(top)
+		}
+	}
+
+	page := top.items
+	slices.SortFunc(page, func(a, b *safeQuote) int { return top.compare(a.position(), b.position()) })
+	return page, nil
+}
+
+func (db *MemDB) matches(sQuote *safeQuote, q entities.QuoteQuery) bool {
+	if sQuote.isDeleted() {
+		return false
+	}
+	if q.MinLength != nil && sQuote.length < *q.MinLength {
+		return false
+	}
+	if q.MaxLength != nil && sQuote.length > *q.MaxLength {
+		return false
+	}
+	if q.CreatedAfter != nil && sQuote.CreatedAt.Before(*q.CreatedAfter) {
+		return false
+	}
+	if q.CreatedBefore != nil && !sQuote.CreatedAt.Before(*q.CreatedBefore) {
+		return false
+	}
+	if !matchesAttribution(sQuote.Quote, q.Attributions) {
+		return false
+	}
+	return true
+}
+
+// сравнение позиций в порядке запроса; автор сравнивается по ключу индекса, как в ListAuthors.
+// ID делает порядок полным, чтобы курсор был однозначным
+func positionCompare(q entities.QuoteQuery) func(a, b entities.Position) int {
+	return func(a, b entities.Position) int {
+		var c int
+		switch q.SortBy {
+		case entities.SortByAuthor:
+			c = cmp.Compare(a.Author, b.Author)
+		case entities.SortByLength:
+			c = cmp.Compare(a.Length, b.Length)
+		case entities.SortByCreated:
+			c = a.Created.Compare(b.Created)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if q.Desc {
+			c = -c
+		}
+		return c
+	}
+}
+
+// куча с наибольшим по порядку запроса элементом наверху - его и выбрасываем при переполнении
+type quoteHeap struct {
+	items   []*safeQuote
+	compare func(a, b entities.Position) int
+}
+
+func (h *quoteHeap) Len() int { return len(h.items) }
+func (h *quoteHeap) Less(i, j int) bool {
+	return h.compare(h.items[i].position(), h.items[j].position()) > 0
+}
+func (h *quoteHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *quoteHeap) Push(x any)    { h.items = append(h.items, x.(*safeQuote)) }
+func (h *quoteHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// ID цитат автора по возрастанию; вызывается под блокировкой на чтение
+func (db *MemDB) authorIDs(author string) []int {
+	entry := db.authorIndex[db.resolveAuthor(author)]
+	if entry == nil {
+		return nil
+	}
+	ids := make([]int, 0, len(entry.quotes))
+	for id := range entry.quotes {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,401 @@
+package memdb
+
+import (
+	"cmp"
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"quote_book/pkg/entities"
+	"slices"
+	"sync"
+)
+
+var attributionStatuses = []entities.AttributionStatus{
+	entities.AttributionUnverified,
+	entities.AttributionVerified,
+	entities.AttributionDisputed,
+	entities.AttributionMisattributed,
+}
+
+// живые ID по возрастанию: k-й элемент за O(1), вставка и удаление - сдвигом хвоста.
+// Новые цитаты получают наибольший ID, поэтому вставка обычно дописывает в конец
+type liveIDs struct {
+	ids []int
+}
+
+func (s *liveIDs) add(id int) {
+	if i, found := slices.BinarySearch(s.ids, id); !found {
+		s.ids = slices.Insert(s.ids, i, id)
+	}
+}
+
+func (s *liveIDs) remove(id int) {
+	if i, found := slices.BinarySearch(s.ids, id); found {
+		s.ids = slices.Delete(s.ids, i, i+1)
+	}
+}
+
+func (s *liveIDs) count() int   { return len(s.ids) }
+func (s *liveIDs) at(k int) int { return s.ids[k] }
+
+func (s *liveIDs) rank(id int) (int, bool) {
+	return slices.BinarySearch(s.ids, id)
+}
+
+// кандидаты случайного выбора в устойчивом для одних и тех же данных порядке
+type candidates interface {
+	count() int
+	at(k int) int // ID k-го кандидата
+}
+
+// кандидаты по возрастанию ID
+type orderedCandidates interface {
+	candidates
+	rank(id int) (int, bool) // сколько кандидатов меньше id и есть ли id среди кандидатов
+}
+
+// индексы живых цитат для случайного выбора без повторных попыток.
+// Меняются под блокировкой базы на запись, а при удалении - под randomMu
+type randomIndex struct {
+	byStatus    map[entities.AttributionStatus]*fenwick // 1 в позиции ID живой цитаты с этим статусом
+	lengths     fenwick                                 // число живых цитат каждой длины
+	byLength    map[int]*liveIDs
+	weights     fenwick        // вес в позиции ID живой цитаты
+	authors     fenwick        // 1 в слоте автора, у которого есть живые цитаты
+	authorSlots []*authorEntry // слот -> автор
+	freeSlots   []int          // слоты авторов, у которых не осталось живых цитат
+}
+
+func newRandomIndex() *randomIndex {
+	ri := &randomIndex{
+		byStatus: make(map[entities.AttributionStatus]*fenwick, len(attributionStatuses)),
+		byLength: make(map[int]*liveIDs),
+	}
+	for _, status := range attributionStatuses {
+		ri.byStatus[status] = &fenwick{}
+	}
+	return ri
+}
+
+func (ri *randomIndex) add(sQuote *safeQuote) {
+	// деревья статусов растут вместе, чтобы по ним можно было искать разом
+	for _, f := range ri.byStatus {
+		f.grow(sQuote.ID + 1)
+	}
+	ri.byStatus[attributionOf(sQuote.Quote)].add(sQuote.ID, 1)
+	ri.weights.grow(sQuote.ID + 1)
+	ri.weights.add(sQuote.ID, int64(weightOf(sQuote.Quote)))
+
+	ri.lengths.grow(sQuote.length + 1)
+	ri.lengths.add(sQuote.length, 1)
+	bucket := ri.byLength[sQuote.length]
+	if bucket == nil {
+		bucket = &liveIDs{}
+		ri.byLength[sQuote.length] = bucket
+	}
+	bucket.add(sQuote.ID)
+}
+
+func (ri *randomIndex) remove(sQuote *safeQuote) {
+	ri.byStatus[attributionOf(sQuote.Quote)].add(sQuote.ID, -1)
+	ri.weights.add(sQuote.ID, -int64(weightOf(sQuote.Quote)))
+
+	ri.lengths.add(sQuote.length, -1)
+	if bucket := ri.byLength[sQuote.length]; bucket != nil {
+		bucket.remove(sQuote.ID)
+		if bucket.count() == 0 {
+			delete(ri.byLength, sQuote.length)
+		}
+	}
+}
+
+// живые цитаты автора для случайного выбора. Пока они есть, автор занимает слот в дереве авторов,
+// чтобы можно было выбрать сначала автора, потом его цитату
+func (ri *randomIndex) addAuthorID(entry *authorEntry, id int) {
+	if entry.ids.count() == 0 {
+		if n := len(ri.freeSlots); n > 0 {
+			entry.slot = ri.freeSlots[n-1]
+			ri.freeSlots = ri.freeSlots[:n-1]
+		} else {
+			entry.slot = len(ri.authorSlots)
+			ri.authorSlots = append(ri.authorSlots, nil)
+			ri.authors.grow(entry.slot + 1)
+		}
+		ri.authorSlots[entry.slot] = entry
+		ri.authors.add(entry.slot, 1)
+	}
+	entry.ids.add(id)
+}
+
+func (ri *randomIndex) removeAuthorID(entry *authorEntry, id int) {
+	if entry.ids.count() == 0 {
+		return
+	}
+	entry.ids.remove(id)
+	if entry.ids.count() == 0 {
+		ri.releaseAuthor(entry)
+	}
+}
+
+// автор без живых цитат или исчезнувший из индекса при слиянии освобождает слот
+func (ri *randomIndex) releaseAuthor(entry *authorEntry) {
+	ri.authors.add(entry.slot, -1)
+	ri.authorSlots[entry.slot] = nil
+	ri.freeSlots = append(ri.freeSlots, entry.slot)
+}
+
+// живые цитаты с любым из статусов, по возрастанию ID
+type statusView []*fenwick
+
+func (v statusView) count() int {
+	var n int64
+	for _, f := range v {
+		n += f.total()
+	}
+	return int(n)
+}
+
+func (v statusView) at(k int) int {
+	return findIn(v, int64(k))
+}
+
+func (v statusView) rank(id int) (int, bool) {
+	var before, through int64
+	for _, f := range v {
+		before += f.prefix(id)
+		through += f.prefix(id + 1)
+	}
+	return int(before), through > before
+}
+
+func (ri *randomIndex) statusView(statuses []entities.AttributionStatus) statusView {
+	view := make(statusView, 0, len(statuses))
+	for _, status := range statuses {
+		if f := ri.byStatus[status]; f != nil && !slices.Contains(view, f) {
+			view = append(view, f)
+		}
+	}
+	return view
+}
+
+// живые цитаты не длиннее max: по длине, при равной длине по ID
+type lengthView struct {
+	ri  *randomIndex
+	max int
+}
+
+func (v lengthView) count() int {
+	if v.max < 0 {
+		return 0
+	}
+	return int(v.ri.lengths.prefix(v.max + 1))
+}
+
+func (v lengthView) at(k int) int {
+	length := v.ri.lengths.find(int64(k))
+	return v.ri.byLength[length].at(k - int(v.ri.lengths.prefix(length)))
+}
+
+// кандидаты под фильтры q. Берем самый узкий из индексов, которые задействованы фильтрами;
+// если фильтр один, выбираем прямо из индекса, иначе проверяем остальные фильтры проходом только по нему.
+// Вызывается под блокировкой на чтение и randomMu на чтение
+func (db *MemDB) randomCandidates(ctx context.Context, q entities.RandomQuery) (candidates, error) {
+	var views []candidates
+	if len(q.Attributions) > 0 {
+		views = append(views, db.random.statusView(q.Attributions))
+	}
+
+	authorKey := ""
+	if q.Author != "" {
+		authorKey = db.resolveAuthor(q.Author)
+		entry := db.authorIndex[authorKey]
+		if entry == nil {
+			return &liveIDs{}, nil
+		}
+		views = append(views, &entry.ids)
+	}
+
+	tags := make([]string, 0, len(q.Tags))
+	for _, tag := range q.Tags {
+		tag = entities.NormalizeTag(tag)
+		entry := db.tagIndex[tag]
+		if entry == nil {
+			return &liveIDs{}, nil
+		}
+		tags = append(tags, tag)
+		views = append(views, &entry.ids)
+	}
+
+	if q.MaxLength != nil {
+		views = append(views, lengthView{ri: db.random, max: *q.MaxLength})
+	}
+
+	switch len(views) {
+	case 0:
+		return db.random.statusView(attributionStatuses), nil
+	case 1:
+		return views[0], nil
+	}
+
+	narrowest := slices.MinFunc(views, func(a, b candidates) int { return cmp.Compare(a.count(), b.count()) })
+	matched := &liveIDs{}
+	for k := range narrowest.count() {
+		if (k+1)%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		id := narrowest.at(k)
+		sQuote := db.quotes[id]
+		if authorKey != "" && sQuote.authorKey != authorKey {
+			continue
+		}
+		if q.MaxLength != nil && sQuote.length > *q.MaxLength {
+			continue
+		}
+		if !matchesAttribution(sQuote.Quote, q.Attributions) || !hasTags(sQuote.Tags, tags) {
+			continue
+		}
+		matched.ids = append(matched.ids, id)
+	}
+	// порядок прохода - порядок индекса, а индекс по длине упорядочен не по ID
+	slices.Sort(matched.ids)
+	return matched, nil
+}
+
+// кандидаты по возрастанию ID: из всех индексов в другом порядке только индекс по длине
+func inIDOrder(c candidates) orderedCandidates {
+	if ordered, ok := c.(orderedCandidates); ok {
+		return ordered
+	}
+	ids := make([]int, 0, c.count())
+	for k := range c.count() {
+		ids = append(ids, c.at(k))
+	}
+	slices.Sort(ids)
+	return &liveIDs{ids: ids}
+}
+
+// генератор для seed: одна и та же строка дает одну и ту же последовательность
+// (алгоритм math/rand.NewSource не меняется между версиями Go)
+func seededRand(seed string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// rand.Source, которым можно пользоваться из нескольких горутин
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// tags упорядочены, как в цитате
+func hasTags(tags, want []string) bool {
+	for _, tag := range want {
+		if _, found := slices.BinarySearch(tags, tag); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// случайная живая цитата, подходящая под q: выбор сразу из индекса, без повторных попыток.
+// Блокировка на чтение (для работы GC)
+func (db *MemDB) GetRandomQuote(ctx context.Context, q entities.RandomQuery) (entities.Quote, error) {
+	quotes, err := db.GetRandomQuotes(ctx, q, 1)
+	if err != nil {
+		return entities.Quote{}, err
+	}
+	if len(quotes) == 0 {
+		return entities.Quote{}, entities.Errorf(entities.ErrNotFound, "no matching quotes")
+	}
+	return quotes[0], nil
+}
+
+// n разных случайных цитат под фильтры q в случайном порядке; если подходящих меньше - все.
+// С q.Seed выбор детерминирован: номера, выпавшие генератору seed, отсчитываются по живым подходящим цитатам
+// в порядке ID, поэтому на тех же данных seed дает те же цитаты. С q.Bag цитаты тянутся из мешка клиента
+// и не повторяются, пока он не увидит все подходящие. q.Mode задает, равновероятны цитаты, авторы или выбор идет
+// по весу цитат. Блокировка на чтение (для работы GC)
+func (db *MemDB) GetRandomQuotes(ctx context.Context, q entities.RandomQuery, n int) ([]entities.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+	db.randomMu.RLock()
+	defer db.randomMu.RUnlock()
+
+	c, err := db.randomCandidates(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	rng := db.rand
+	if q.Seed != "" {
+		rng = seededRand(q.Seed)
+	}
+	// индексы всей базы годятся, только если фильтров нет; с seed выбор должен зависеть только от данных,
+	// а порядок слотов авторов зависит от истории
+	indexed := q.Seed == "" && q.Author == "" && len(q.Tags) == 0 && q.MaxLength == nil && len(q.Attributions) == 0
+
+	var ids []int
+	switch {
+	case q.Bag != "":
+		bag := db.bags.acquire(q.Bag, db.now())
+		defer bag.mu.Unlock()
+		ids = bag.draw(inIDOrder(c), n, rng)
+	case q.Mode == entities.RandomWeighted:
+		ids = db.sampleWeighted(c, indexed, n, rng)
+	case q.Mode == entities.RandomByAuthor:
+		ids = db.sampleByAuthor(c, indexed, n, rng)
+	case q.Seed != "":
+		ids = sampleIDs(inIDOrder(c), n, rng)
+	default:
+		ids = sampleIDs(c, n, rng)
+	}
+	quotes := make([]entities.Quote, 0, len(ids))
+	for _, id := range ids {
+		quotes = append(quotes, *db.quotes[id].Quote)
+	}
+	return quotes, nil
+}
+
+// n разных кандидатов: первые n шагов тасования Фишера-Йетса над номерами кандидатов.
+// Перестановка хранится только для затронутых номеров, поэтому выходит n выборов без повторных попыток
+// и без копирования всех кандидатов
+func sampleIDs(c candidates, n int, rng *rand.Rand) []int {
+	total := c.count()
+	n = min(n, total)
+
+	moved := make(map[int]int, n) // номер -> какой кандидат сейчас на этом месте, если не он сам
+	at := func(i int) int {
+		if k, ok := moved[i]; ok {
+			return k
+		}
+		return i
+	}
+
+	ids := make([]int, 0, n)
+	for i := range n {
+		j := i + rng.Intn(total-i)
+		picked := at(j)
+		moved[j] = at(i)
+		ids = append(ids, c.at(picked))
+	}
+	return ids
+}
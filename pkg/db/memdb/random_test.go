@@ -0,0 +1,590 @@
+package memdb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// ID всех живых цитат под фильтры q, перебором
+func expectedRandom(t *testing.T, db *memdb.MemDB, q entities.RandomQuery) []int {
+	t.Helper()
+
+	quotes, err := db.GetAllQuotes(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllQuotes failed: %v", err)
+	}
+	var ids []int
+	for _, quote := range quotes {
+		if q.Author != "" && !strings.EqualFold(strings.TrimSpace(q.Author), quote.Author) {
+			continue
+		}
+		if q.MaxLength != nil && utf8.RuneCountInString(quote.Text) > *q.MaxLength {
+			continue
+		}
+		if len(q.Attributions) > 0 && !slices.Contains(q.Attributions, quote.Attribution) {
+			continue
+		}
+		if slices.ContainsFunc(q.Tags, func(tag string) bool { return !slices.Contains(quote.Tags, tag) }) {
+			continue
+		}
+		ids = append(ids, quote.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// выбираем много раз: каждая выбранная цитата подходит, и каждая подходящая выпадает
+func sampleRandom(t *testing.T, db *memdb.MemDB, q entities.RandomQuery, n int) []int {
+	t.Helper()
+
+	seen := make(map[int]bool)
+	for range n {
+		quote, err := db.GetRandomQuote(context.Background(), q)
+		if errors.Is(err, entities.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("GetRandomQuote(%+v) failed: %v", q, err)
+		}
+		seen[quote.ID] = true
+	}
+	ids := make([]int, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestRandomFilters(t *testing.T) {
+	dir := t.TempDir()
+	opts := []memdb.Option{memdb.WithWAL(filepath.Join(dir, "quotes.wal")), memdb.WithSnapshots(dir, 0)}
+	ctx := context.Background()
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	authors := []string{"Pushkin", "Tolstoy", "Gogol"}
+	statuses := []entities.AttributionStatus{entities.AttributionVerified, entities.AttributionUnverified, entities.AttributionDisputed}
+	tags := [][]string{nil, {"x"}, {"x", "y"}, {"y"}}
+	for i := range 40 {
+		_ = db.AddQuote(ctx, entities.Quote{
+			Text:        strings.Repeat("q", 1+i%9),
+			Author:      authors[i%3],
+			Tags:        tags[i%4],
+			Attribution: statuses[i/3%3],
+		})
+	}
+
+	// индексы должны следовать за удалением, правкой и слиянием
+	for id := 0; id < 40; id += 7 {
+		_ = db.DeleteQuote(ctx, id)
+	}
+	for id := 1; id < 40; id += 6 {
+		_, _ = db.UpdateQuote(ctx, id, func(q *entities.Quote) error {
+			q.Text += "qqq"
+			q.Tags = append(q.Tags, "y")
+			q.Attribution = entities.AttributionMisattributed
+			return nil
+		})
+	}
+	if _, err := db.MergeAuthors(ctx, "Gogol", "Pushkin"); err != nil {
+		t.Fatalf("MergeAuthors failed: %v", err)
+	}
+
+	short, tiny, negative := 4, 2, -1
+	queries := []entities.RandomQuery{
+		{},
+		{Author: "Pushkin"},
+		{Author: " tolstoy "},
+		{Tags: []string{"x"}},
+		{Tags: []string{"x", "y"}},
+		{MaxLength: &short},
+		{Author: "Pushkin", MaxLength: &short, Tags: []string{"y"}},
+		{Attributions: []entities.AttributionStatus{entities.AttributionVerified}},
+		{Attributions: []entities.AttributionStatus{entities.AttributionVerified, entities.AttributionUnverified}, Tags: []string{"x"}},
+		{Attributions: []entities.AttributionStatus{entities.AttributionMisattributed}, MaxLength: &tiny},
+		{Author: "Gogol"},
+		{Author: "Lermontov"},
+		{Tags: []string{"z"}},
+		{MaxLength: &negative},
+	}
+	check := func(db *memdb.MemDB) {
+		t.Helper()
+
+		for _, q := range queries {
+			want := expectedRandom(t, db, q)
+			if q.Author == "Gogol" {
+				want = expectedRandom(t, db, entities.RandomQuery{Author: "Pushkin"})
+			}
+			if got := sampleRandom(t, db, q, 1000); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("GetRandomQuote(%+v): expected %v, got %v", q, want, got)
+			}
+		}
+	}
+	check(db)
+
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	_ = db.DeleteQuote(ctx, 2)
+	_ = db.AddQuote(ctx, entities.Quote{Text: "qq", Author: "Tolstoy", Tags: []string{"x"}})
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	check(newTestDB(t, opts...))
+}
+
+func TestRandomQuotesDistinct(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	for i := range 10 {
+		_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", i), Author: []string{"A", "B"}[i%2]})
+	}
+	_ = db.DeleteQuote(ctx, 4)
+
+	seen := make(map[int]int)
+	for range 200 {
+		quotes, err := db.GetRandomQuotes(ctx, entities.RandomQuery{Author: "A"}, 3)
+		if err != nil {
+			t.Fatalf("GetRandomQuotes failed: %v", err)
+		}
+		ids := make(map[int]bool)
+		for _, quote := range quotes {
+			if quote.Author != "A" || quote.ID == 4 || ids[quote.ID] {
+				t.Fatalf("GetRandomQuotes: unexpected or repeated quote %d in %v", quote.ID, quotes)
+			}
+			ids[quote.ID] = true
+			seen[quote.ID]++
+		}
+		if len(quotes) != 3 {
+			t.Fatalf("GetRandomQuotes: expected 3 quotes, got %d", len(quotes))
+		}
+	}
+	if len(seen) != 4 {
+		t.Fatalf("GetRandomQuotes: expected all 4 live quotes of A to appear, got %v", seen)
+	}
+
+	// подходящих меньше, чем просили, - отдаем все
+	quotes, _ := db.GetRandomQuotes(ctx, entities.RandomQuery{Author: "B"}, 100)
+	ids := make([]int, 0, len(quotes))
+	for _, quote := range quotes {
+		ids = append(ids, quote.ID)
+	}
+	slices.Sort(ids)
+	if fmt.Sprint(ids) != "[1 3 5 7 9]" {
+		t.Fatalf("GetRandomQuotes over the pool size: expected [1 3 5 7 9], got %v", ids)
+	}
+
+	quotes, err := db.GetRandomQuotes(ctx, entities.RandomQuery{Author: "C"}, 5)
+	if err != nil || len(quotes) != 0 {
+		t.Fatalf("GetRandomQuotes without matches: expected no quotes, got %v, %v", quotes, err)
+	}
+}
+
+func TestGetAliveID(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	if _, err := db.GetAliveID(); !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("GetAliveID on empty DB: expected not found, got %v", err)
+	}
+
+	for i := range 5 {
+		_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", i), Author: "A"})
+	}
+	// почти все удалены: раньше выбор мог долго попадать в удаленные
+	for id := range 4 {
+		_ = db.DeleteQuote(ctx, id)
+	}
+	for range 50 {
+		if id, err := db.GetAliveID(); err != nil || id != 4 {
+			t.Fatalf("GetAliveID: expected 4, got %d, %v", id, err)
+		}
+	}
+
+	_ = db.DeleteQuote(ctx, 4)
+	if _, err := db.GetAliveID(); !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("GetAliveID with all quotes deleted: expected not found, got %v", err)
+	}
+}
+
+func TestRandomSeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.wal")
+	ctx := context.Background()
+
+	fill := func(db *memdb.MemDB) {
+		for i := range 30 {
+			_ = db.AddQuote(ctx, entities.Quote{Text: strings.Repeat("q", 1+i%7), Author: []string{"A", "B"}[i%2]})
+		}
+		_ = db.DeleteQuote(ctx, 3)
+	}
+	pick := func(db *memdb.MemDB, q entities.RandomQuery) string {
+		t.Helper()
+		quotes, err := db.GetRandomQuotes(ctx, q, 4)
+		if err != nil {
+			t.Fatalf("GetRandomQuotes(%+v) failed: %v", q, err)
+		}
+		ids := make([]int, 0, len(quotes))
+		for _, quote := range quotes {
+			ids = append(ids, quote.ID)
+		}
+		return fmt.Sprint(ids)
+	}
+
+	db, err := memdb.New(memdb.WithWAL(path))
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	fill(db)
+	other := newTestDB(t)
+	fill(other)
+
+	short := 3
+	queries := []entities.RandomQuery{
+		{Seed: "share-1"},
+		{Seed: "share-1", Author: "B"},
+		{Seed: "share-1", MaxLength: &short},
+		{Seed: "share-1", Author: "A", MaxLength: &short},
+	}
+	want := make([]string, len(queries))
+	for i, q := range queries {
+		want[i] = pick(db, q)
+		if again := pick(db, q); again != want[i] {
+			t.Errorf("seeded %+v: %s, then %s", q, want[i], again)
+		}
+		if got := pick(other, q); got != want[i] {
+			t.Errorf("seeded %+v on the same data in another DB: expected %s, got %s", q, want[i], got)
+		}
+	}
+
+	// разные seed дают разные цитаты
+	picks := make(map[string]bool)
+	for i := range 20 {
+		picks[pick(db, entities.RandomQuery{Seed: fmt.Sprint("seed-", i)})] = true
+	}
+	if len(picks) < 10 {
+		t.Errorf("20 seeds gave only %d different picks", len(picks))
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db = newTestDB(t, memdb.WithWAL(path))
+	for i, q := range queries {
+		if got := pick(db, q); got != want[i] {
+			t.Errorf("seeded %+v after restart: expected %s, got %s", q, want[i], got)
+		}
+	}
+}
+
+func TestRandSource(t *testing.T) {
+	ctx := context.Background()
+
+	sequence := func() string {
+		db := newTestDB(t, memdb.WithRandSource(rand.NewSource(7)))
+		for i := range 20 {
+			_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", i), Author: "A"})
+		}
+
+		var ids []int
+		for range 10 {
+			quote, err := db.GetRandomQuote(ctx, entities.RandomQuery{})
+			if err != nil {
+				t.Fatalf("GetRandomQuote failed: %v", err)
+			}
+			id, _ := db.GetAliveID()
+			ids = append(ids, quote.ID, id)
+		}
+		return fmt.Sprint(ids)
+	}
+
+	if first, second := sequence(), sequence(); first != second {
+		t.Fatalf("the same source gave different picks: %s and %s", first, second)
+	}
+}
+
+func TestShuffleBag(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	db := newTestDB(t, memdb.WithClock(func() time.Time { return now }), memdb.WithBagTTL(time.Minute))
+	ctx := context.Background()
+
+	for i := range 10 {
+		_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", i), Author: []string{"A", "B"}[i%2]})
+	}
+	_ = db.DeleteQuote(ctx, 9)
+
+	draw := func(q entities.RandomQuery, n int) []int {
+		t.Helper()
+		quotes, err := db.GetRandomQuotes(ctx, q, n)
+		if err != nil {
+			t.Fatalf("GetRandomQuotes(%+v, %d) failed: %v", q, n, err)
+		}
+		ids := make([]int, 0, len(quotes))
+		for _, quote := range quotes {
+			ids = append(ids, quote.ID)
+		}
+		return ids
+	}
+	// круг из одиночных запросов - перестановка живых цитат
+	pass := func(q entities.RandomQuery, size int) []int {
+		t.Helper()
+		var ids []int
+		for range size {
+			ids = append(ids, draw(q, 1)...)
+		}
+		sorted := slices.Clone(ids)
+		slices.Sort(sorted)
+		if len(slices.Compact(sorted)) != size {
+			t.Fatalf("bag %+v repeated a quote within a round: %v", q, ids)
+		}
+		return sorted
+	}
+
+	a, b := entities.RandomQuery{Bag: "a"}, entities.RandomQuery{Bag: "b"}
+	for round := range 3 {
+		// мешки разных клиентов не мешают друг другу
+		_ = draw(b, 1)
+		if got := pass(a, 9); fmt.Sprint(got) != "[0 1 2 3 4 5 6 7 8]" {
+			t.Fatalf("round %d: expected all live quotes, got %v", round, got)
+		}
+	}
+
+	// удаления и добавления посреди круга
+	first := draw(a, 4)
+	slices.Sort(first)
+	deleted := 0
+	for slices.Contains(first, deleted) {
+		deleted++
+	}
+	_ = db.DeleteQuote(ctx, deleted)
+	_ = db.DeleteQuote(ctx, first[0])
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q10", Author: "A"})
+	rest := pass(a, 5)
+	for _, id := range rest {
+		if id == deleted || id == first[0] || slices.Contains(first, id) {
+			t.Fatalf("rest of the round %v: unexpected quote %d (drawn %v, deleted %d)", rest, id, first, deleted)
+		}
+	}
+	if !slices.Contains(rest, 10) {
+		t.Fatalf("quote added during the round is missing from it: %v", rest)
+	}
+
+	// count на границе круга: ответ без повторов
+	_ = draw(a, 5)
+	got := draw(a, 7)
+	sorted := slices.Clone(got)
+	slices.Sort(sorted)
+	if len(slices.Compact(sorted)) != 7 {
+		t.Fatalf("count across rounds: expected 7 different quotes, got %v", got)
+	}
+
+	// с фильтром круг идет по подходящим
+	for i := range 3 {
+		_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", 11+i), Author: "C"})
+	}
+	if got := pass(entities.RandomQuery{Bag: "c", Author: "C"}, 3); fmt.Sprint(got) != "[11 12 13]" {
+		t.Fatalf("bag with author filter: expected [11 12 13], got %v", got)
+	}
+
+	// мешок без запросов дольше ttl забывается: после двух из трех оставшаяся уже не обязательна
+	three := entities.RandomQuery{Author: "C"}
+	restarted := 0
+	for i := range 50 {
+		q := three
+		q.Bag = fmt.Sprint("ttl-", i)
+		drawn := draw(q, 2)
+		now = now.Add(2 * time.Minute)
+		if next := draw(q, 1)[0]; slices.Contains(drawn, next) {
+			restarted++
+		}
+	}
+	if restarted == 0 {
+		t.Fatal("expired bags were not forgotten")
+	}
+	q := entities.RandomQuery{Bag: "fresh", Author: "C"}
+	for range 20 {
+		drawn := draw(q, 2)
+		now = now.Add(30 * time.Second)
+		if next := draw(q, 1)[0]; slices.Contains(drawn, next) {
+			t.Fatalf("bag within ttl repeated quote %d after %v", next, drawn)
+		}
+	}
+}
+
+func TestShuffleBagLimit(t *testing.T) {
+	db := newTestDB(t, memdb.WithMaxBags(2))
+	ctx := context.Background()
+
+	for i := range 3 {
+		_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", i), Author: "A"})
+	}
+	draw := func(bag string, n int) []int {
+		t.Helper()
+		quotes, err := db.GetRandomQuotes(ctx, entities.RandomQuery{Bag: bag}, n)
+		if err != nil {
+			t.Fatalf("GetRandomQuotes(%q, %d) failed: %v", bag, n, err)
+		}
+		ids := make([]int, 0, len(quotes))
+		for _, quote := range quotes {
+			ids = append(ids, quote.ID)
+		}
+		return ids
+	}
+
+	// недавно использованный мешок переживает появление нового
+	for i := range 20 {
+		drawn := draw("keep", 2)
+		_ = draw(fmt.Sprint("other-", i), 1)
+		if next := draw("keep", 1)[0]; slices.Contains(drawn, next) {
+			t.Fatalf("recently used bag repeated quote %d after %v", next, drawn)
+		}
+	}
+
+	// сверх лимита забывается давно не использованный: после двух из трех оставшаяся уже не обязательна
+	restarted := 0
+	for i := range 50 {
+		bag := fmt.Sprint("evicted-", i)
+		drawn := draw(bag, 2)
+		_ = draw(fmt.Sprint("new-", i, "-1"), 1)
+		_ = draw(fmt.Sprint("new-", i, "-2"), 1)
+		if next := draw(bag, 1)[0]; slices.Contains(drawn, next) {
+			restarted++
+		}
+	}
+	if restarted == 0 {
+		t.Fatal("bags over the limit were not evicted")
+	}
+}
+
+// доля выборов каждой цитаты за n одиночных запросов
+func frequencies(t *testing.T, db *memdb.MemDB, q entities.RandomQuery, n int) map[int]float64 {
+	t.Helper()
+
+	freq := make(map[int]float64)
+	for range n {
+		quote, err := db.GetRandomQuote(context.Background(), q)
+		if err != nil {
+			t.Fatalf("GetRandomQuote(%+v) failed: %v", q, err)
+		}
+		freq[quote.ID] += 1 / float64(n)
+	}
+	return freq
+}
+
+func TestRandomModes(t *testing.T) {
+	dir := t.TempDir()
+	opts := []memdb.Option{
+		memdb.WithWAL(filepath.Join(dir, "quotes.wal")),
+		memdb.WithSnapshots(dir, 0),
+		memdb.WithRandSource(rand.NewSource(1)),
+	}
+	ctx := context.Background()
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	// 0-7 - A, 8 - B, 9 - C; у 0 вес 10, у остальных 1
+	for i := range 10 {
+		author := "A"
+		if i >= 8 {
+			author = []string{"B", "C"}[i-8]
+		}
+		weight := 0
+		if i == 0 {
+			weight = 10
+		}
+		_ = db.AddQuote(ctx, entities.Quote{Text: fmt.Sprint("Q", i), Author: author, Tags: []string{"t"}, Weight: weight})
+	}
+	for _, weight := range []int{-1, 1001} {
+		if err := db.AddQuote(ctx, entities.Quote{Text: "Q", Author: "A", Weight: weight}); !errors.Is(err, entities.ErrValidation) {
+			t.Fatalf("AddQuote with weight %d: expected validation error, got %v", weight, err)
+		}
+	}
+
+	near := func(name string, got, want float64) {
+		t.Helper()
+		if got < want-0.05 || got > want+0.05 {
+			t.Errorf("%s: expected share about %.2f, got %.3f", name, want, got)
+		}
+	}
+	modes := func(want map[entities.RandomMode]map[int]float64) {
+		t.Helper()
+		// с фильтром и seed выбор идет не по индексам всей базы, а по кандидатам
+		for _, q := range []entities.RandomQuery{{}, {Tags: []string{"t"}}} {
+			for mode, shares := range want {
+				q.Mode = mode
+				freq := frequencies(t, db, q, 4000)
+				for id, share := range shares {
+					near(fmt.Sprintf("%s%v quote %d", mode, q.Tags, id), freq[id], share)
+				}
+			}
+		}
+	}
+
+	modes(map[entities.RandomMode]map[int]float64{
+		entities.RandomUniform:  {0: 0.1, 8: 0.1},
+		entities.RandomByAuthor: {0: 1.0 / 24, 8: 1.0 / 3, 9: 1.0 / 3},
+		entities.RandomWeighted: {0: 10.0 / 19, 8: 1.0 / 19},
+	})
+
+	// выбор нескольких - без повторов, пока есть из чего
+	for _, mode := range []entities.RandomMode{entities.RandomByAuthor, entities.RandomWeighted} {
+		for _, q := range []entities.RandomQuery{{Mode: mode}, {Mode: mode, Tags: []string{"t"}}, {Mode: mode, Seed: "s"}} {
+			quotes, err := db.GetRandomQuotes(ctx, q, 12)
+			if err != nil {
+				t.Fatalf("GetRandomQuotes(%+v) failed: %v", q, err)
+			}
+			ids := make([]int, 0, len(quotes))
+			for _, quote := range quotes {
+				ids = append(ids, quote.ID)
+			}
+			slices.Sort(ids)
+			if fmt.Sprint(ids) != "[0 1 2 3 4 5 6 7 8 9]" {
+				t.Fatalf("GetRandomQuotes(%+v, 12): expected all quotes once, got %v", q, ids)
+			}
+		}
+	}
+
+	// индексы следуют за удалением, слиянием, добавлением и сменой веса
+	_ = db.DeleteQuote(ctx, 8)
+	if _, err := db.MergeAuthors(ctx, "C", "A"); err != nil {
+		t.Fatalf("MergeAuthors failed: %v", err)
+	}
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q10", Author: "D", Tags: []string{"t"}, Weight: 8})
+	_, _ = db.UpdateQuote(ctx, 0, func(q *entities.Quote) error {
+		q.Weight = 2
+		return nil
+	})
+	want := map[entities.RandomMode]map[int]float64{
+		entities.RandomByAuthor: {0: 1.0 / 18, 9: 1.0 / 18, 10: 0.5, 8: 0},
+		entities.RandomWeighted: {0: 2.0 / 18, 10: 8.0 / 18, 8: 0},
+	}
+	modes(want)
+
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	_ = db.DeleteQuote(ctx, 1)
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db = newTestDB(t, opts...)
+	want[entities.RandomByAuthor][0] = 1.0 / 16
+	want[entities.RandomWeighted][0] = 2.0 / 17
+	want[entities.RandomWeighted][10] = 8.0 / 17
+	modes(want)
+}
@@ -0,0 +1,271 @@
+package memdb
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"math"
+	"quote_book/pkg/analysis"
+	"quote_book/pkg/entities"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// параметры BM25
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// обратный индекс по тексту цитат. Меняется под блокировкой базы на запись,
+// кроме счетчиков живых документов: логическое удаление идет под блокировкой на чтение.
+// Записи удаленных цитат остаются в индексе до GC, поиск их пропускает.
+type textIndex struct {
+	analyzer analysis.Selector        // анализатор выбирается по тексту каждой цитаты и запроса
+	postings map[string]map[int][]int // терм -> ID цитаты -> позиции терма в тексте
+	docLen   map[int]int              // число термов в цитате
+	liveDocs atomic.Int64
+	liveLen  atomic.Int64
+}
+
+func newTextIndex(analyzer analysis.Selector) *textIndex {
+	return &textIndex{
+		analyzer: analyzer,
+		postings: make(map[string]map[int][]int),
+		docLen:   make(map[int]int),
+	}
+}
+
+func (idx *textIndex) analyze(text string) []analysis.Token {
+	return idx.analyzer(text).Analyze(text)
+}
+
+func (idx *textIndex) add(id int, text string) {
+	tokens := idx.analyze(text)
+	for _, token := range tokens {
+		if idx.postings[token.Term] == nil {
+			idx.postings[token.Term] = make(map[int][]int)
+		}
+		idx.postings[token.Term][id] = append(idx.postings[token.Term][id], token.Pos)
+	}
+	idx.docLen[id] = len(tokens)
+	idx.liveDocs.Add(1)
+	idx.liveLen.Add(int64(len(tokens)))
+}
+
+// убирает документ из статистики; записи остаются до prune
+func (idx *textIndex) markDeleted(id int) {
+	idx.liveDocs.Add(-1)
+	idx.liveLen.Add(-int64(idx.docLen[id]))
+}
+
+// анализатор детерминирован, поэтому повторный анализ старого текста дает те же термы
+func (idx *textIndex) prune(id int, text string) {
+	for _, token := range idx.analyze(text) {
+		delete(idx.postings[token.Term], id)
+		if len(idx.postings[token.Term]) == 0 {
+			delete(idx.postings, token.Term)
+		}
+	}
+	delete(idx.docLen, id)
+}
+
+// замена текста живой цитаты
+func (idx *textIndex) update(id int, oldText, newText string) {
+	idx.markDeleted(id)
+	idx.prune(id, oldText)
+	idx.add(id, newText)
+}
+
+type searchQuery struct {
+	terms   []string           // все термы запроса без повторов, включая термы фраз
+	phrases [][]analysis.Token // фразы из двух и больше термов; позиции - с пропусками на месте стоп-слов
+}
+
+// слова вне кавычек ищутся по отдельности (все обязательны), в "..." или «...» - как фраза.
+// Весь запрос разбирается одним анализатором, выбранным по его тексту
+func parseSearchQuery(text string, selector analysis.Selector) searchQuery {
+	analyzer := selector(text)
+
+	var q searchQuery
+	seen := make(map[string]bool)
+	addTerms := func(tokens []analysis.Token) {
+		for _, token := range tokens {
+			if !seen[token.Term] {
+				seen[token.Term] = true
+				q.terms = append(q.terms, token.Term)
+			}
+		}
+	}
+
+	for text != "" {
+		start := strings.IndexAny(text, `"«`)
+		if start < 0 {
+			addTerms(analyzer.Analyze(text))
+			break
+		}
+		addTerms(analyzer.Analyze(text[:start]))
+
+		closing := `"`
+		if text[start] != '"' {
+			closing = "»"
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		rest := text[start+size:]
+
+		// незакрытая кавычка - фраза до конца запроса
+		end := strings.Index(rest, closing)
+		if end < 0 {
+			end = len(rest)
+		}
+		phrase := analyzer.Analyze(rest[:end])
+		addTerms(phrase)
+		if len(phrase) > 1 {
+			q.phrases = append(q.phrases, phrase)
+		}
+
+		text = ""
+		if end < len(rest) {
+			text = rest[end+len(closing):]
+		}
+	}
+	return q
+}
+
+// до limit лучших по BM25 цитат, содержащих все слова и фразы запроса
+func (db *MemDB) SearchQuotes(ctx context.Context, text string, limit int) ([]entities.SearchResult, error) {
+	if len(analysis.Tokenize(text)) == 0 {
+		return nil, entities.Errorf(entities.ErrValidation, "empty search query")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// запрос из одних стоп-слов ничего не находит
+	q := parseSearchQuery(text, db.textIndex.analyzer)
+	if len(q.terms) == 0 {
+		return []entities.SearchResult{}, nil
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	idx := db.textIndex
+	lists := make([]map[int][]int, 0, len(q.terms))
+	for _, term := range q.terms {
+		list := idx.postings[term]
+		if len(list) == 0 {
+			return []entities.SearchResult{}, nil
+		}
+		lists = append(lists, list)
+	}
+
+	// idf считаем по живым документам, поэтому удаленные до GC не искажают ранжирование
+	n := float64(idx.liveDocs.Load())
+	avgLen := float64(idx.liveLen.Load()) / max(n, 1)
+	idf := make([]float64, len(lists))
+	for i, list := range lists {
+		df := 0
+		for id := range list {
+			if !db.quotes[id].isDeleted() {
+				df++
+			}
+		}
+		idf[i] = math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	}
+
+	// кандидаты - из самого короткого списка, остальные списки только проверяем
+	shortest := slices.MinFunc(lists, func(a, b map[int][]int) int { return cmp.Compare(len(a), len(b)) })
+
+	top := &resultHeap{}
+	checked := 0
+	for id := range shortest {
+		checked++
+		if checked%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		sQuote := db.quotes[id]
+		if sQuote.isDeleted() || !containsAll(lists, id) || !idx.hasPhrases(q.phrases, id) {
+			continue
+		}
+
+		docLen := float64(idx.docLen[id])
+		score := 0.0
+		for i, list := range lists {
+			tf := float64(len(list[id]))
+			score += idf[i] * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*docLen/avgLen))
+		}
+
+		heap.Push(top, entities.SearchResult{Quote: *sQuote.Quote, Score: score})
+		if top.Len() > limit {
+			heap.Pop(top)
+		}
+	}
+
+	results := top.items
+	slices.SortFunc(results, func(a, b entities.SearchResult) int { return -compareResults(a, b) })
+	return results, nil
+}
+
+func containsAll(lists []map[int][]int, id int) bool {
+	for _, list := range lists {
+		if _, ok := list[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *textIndex) hasPhrases(phrases [][]analysis.Token, id int) bool {
+	for _, phrase := range phrases {
+		if !idx.hasPhrase(phrase, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// фраза найдена, если для какой-то позиции первого терма остальные термы стоят на тех же расстояниях, что и в запросе
+func (idx *textIndex) hasPhrase(phrase []analysis.Token, id int) bool {
+	first := phrase[0]
+	for _, start := range idx.postings[first.Term][id] {
+		found := true
+		for _, token := range phrase[1:] {
+			if _, ok := slices.BinarySearch(idx.postings[token.Term][id], start+token.Pos-first.Pos); !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// больше - лучше: выше счет, при равенстве меньший ID
+func compareResults(a, b entities.SearchResult) int {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c
+	}
+	return cmp.Compare(b.Quote.ID, a.Quote.ID)
+}
+
+// куча с худшим результатом наверху - его выбрасываем при переполнении
+type resultHeap struct {
+	items []entities.SearchResult
+}
+
+func (h *resultHeap) Len() int           { return len(h.items) }
+func (h *resultHeap) Less(i, j int) bool { return compareResults(h.items[i], h.items[j]) < 0 }
+func (h *resultHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *resultHeap) Push(x any)         { h.items = append(h.items, x.(entities.SearchResult)) }
+func (h *resultHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
@@ -0,0 +1,119 @@
+package memdb_test
+
+import (
+	"context"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"testing"
+)
+
+func searchIDs(t *testing.T, db *memdb.MemDB, query string) []int {
+	t.Helper()
+
+	results, err := db.SearchQuotes(context.Background(), query, 10)
+	if err != nil {
+		t.Fatalf("SearchQuotes failed: %v", err)
+	}
+	ids := make([]int, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.Quote.ID)
+	}
+	return ids
+}
+
+func TestSearchQuotes(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Imagination is more important than knowledge.", Author: "Einstein"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Knowledge is power.", Author: "Bacon"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Power corrupts, power.", Author: "Acton"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Жизнь прожить — не поле перейти.", Author: "Пастернак"})
+
+	// Все слова обязательны, регистр и пунктуация не важны
+	ids := searchIDs(t, db, "KNOWLEDGE power")
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("AND query: expected [1], got %v", ids)
+	}
+
+	// При равной длине выше цитата, где слово встречается чаще
+	ids = searchIDs(t, db, "power")
+	if len(ids) != 2 || ids[0] != 2 {
+		t.Fatalf("ranking: expected quote 2 first, got %v", ids)
+	}
+
+	// Фраза должна идти подряд
+	ids = searchIDs(t, db, `"more important"`)
+	if len(ids) != 1 || ids[0] != 0 {
+		t.Fatalf("phrase: expected [0], got %v", ids)
+	}
+	ids = searchIDs(t, db, `"knowledge important"`)
+	if len(ids) != 0 {
+		t.Fatalf("phrase in wrong order: expected nothing, got %v", ids)
+	}
+	ids = searchIDs(t, db, "«поле перейти»")
+	if len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("phrase in guillemets: expected [3], got %v", ids)
+	}
+
+	if _, err := db.SearchQuotes(ctx, " ... ", 10); err == nil {
+		t.Fatal("SearchQuotes with no words should return error")
+	}
+}
+
+func TestSearchQuotesAnalysis(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Любовь долго терпит, милосердствует.", Author: "Апостол Павел"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Во поле берёза стояла.", Author: "Народная песня"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "The only thing we have to fear is fear itself.", Author: "Roosevelt"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Жизнь прожить — не поле перейти.", Author: "Пастернак"})
+
+	// Словоформы сводятся к одной основе
+	if ids := searchIDs(t, db, "о любви"); len(ids) != 1 || ids[0] != 0 {
+		t.Fatalf("inflected form: expected [0], got %v", ids)
+	}
+	if ids := searchIDs(t, db, "fears"); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("english inflected form: expected [2], got %v", ids)
+	}
+	// ё и е не различаются
+	if ids := searchIDs(t, db, "БЕРЕЗА"); len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("yo folding: expected [1], got %v", ids)
+	}
+
+	// Стоп-слова не ищутся, но расстояние между словами фразы учитывается
+	if ids := searchIDs(t, db, "the is"); len(ids) != 0 {
+		t.Fatalf("stop words only: expected nothing, got %v", ids)
+	}
+	if ids := searchIDs(t, db, "«прожить не поле»"); len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("phrase with stop word: expected [3], got %v", ids)
+	}
+	if ids := searchIDs(t, db, "«прожить поле»"); len(ids) != 0 {
+		t.Fatalf("phrase with a gap: expected nothing, got %v", ids)
+	}
+}
+
+func TestSearchIndexFollowsChanges(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	_ = db.AddQuote(ctx, entities.Quote{Text: "old words", Author: "A"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "other words", Author: "A"})
+
+	_, _ = db.UpdateQuote(ctx, 0, func(q *entities.Quote) error {
+		q.Text = "new words"
+		return nil
+	})
+	if ids := searchIDs(t, db, "old"); len(ids) != 0 {
+		t.Fatalf("after update: old text still found in %v", ids)
+	}
+	if ids := searchIDs(t, db, "new"); len(ids) != 1 {
+		t.Fatalf("after update: new text not found, got %v", ids)
+	}
+
+	_ = db.DeleteQuote(ctx, 1)
+	if ids := searchIDs(t, db, "words"); len(ids) != 1 || ids[0] != 0 {
+		t.Fatalf("after delete: expected [0], got %v", ids)
+	}
+}
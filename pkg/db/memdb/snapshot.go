@@ -0,0 +1,260 @@
+package memdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"quote_book/pkg/entities"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// храним два последних снапшота: если последний окажется битым, старший плюс журнал дадут то же состояние
+const snapshotsRetained = 2
+
+const (
+	snapshotPrefix = "snapshot-"
+	snapshotExt    = ".snap"
+)
+
+var errCorruptSnapshot = errors.New("corrupt snapshot")
+
+// формат файла: [crc32c payload uint32][payload JSON]
+type snapshot struct {
+	LSN     uint64            `json:"lsn"` // последняя запись журнала, вошедшая в снапшот
+	NextID  int               `json:"next_id"`
+	Quotes  []entities.Quote  `json:"quotes"`
+	Aliases map[string]string `json:"aliases,omitempty"`
+	Daily   map[string]int    `json:"daily,omitempty"`       // цитаты дня по дням
+	Cycle   []int             `json:"daily_cycle,omitempty"` // выпавшие в текущем круге цитаты дня
+}
+
+func snapshotName(lsn uint64) string {
+	return fmt.Sprintf("%s%020d%s", snapshotPrefix, lsn, snapshotExt)
+}
+
+// снапшоты в каталоге от нового к старому
+func listSnapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), snapshotPrefix) && strings.HasSuffix(e.Name(), snapshotExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+func readSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, errCorruptSnapshot
+	}
+
+	sum := binary.LittleEndian.Uint32(data[:4])
+	payload := data[4:]
+	if crc32.Checksum(payload, crcTable) != sum {
+		return nil, errCorruptSnapshot
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(payload, &snap); err != nil {
+		return nil, errCorruptSnapshot
+	}
+	return &snap, nil
+}
+
+// пишем во временный файл и переименовываем, чтобы на диске не оставалось недописанных снапшотов
+func writeSnapshot(dir string, snap *snapshot) error {
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4+len(payload))
+	binary.LittleEndian.PutUint32(buf[:4], crc32.Checksum(payload, crcTable))
+	copy(buf[4:], payload)
+
+	path := filepath.Join(dir, snapshotName(snap.LSN))
+	tmpPath := path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// последний целый снапшот; битые пропускаются
+func loadLatestSnapshot(dir string) (*snapshot, error) {
+	names, err := listSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		snap, err := readSnapshot(filepath.Join(dir, name))
+		if err != nil {
+			slog.Warn("snapshot: skipping unreadable snapshot", "name", name, "error", err.Error())
+			continue
+		}
+		return snap, nil
+	}
+	return nil, nil
+}
+
+// используется только при старте, блокировки не нужны
+func (db *MemDB) restoreSnapshot(snap *snapshot) {
+	// псевдонимы нужны до индексации авторов
+	for alias, canonical := range snap.Aliases {
+		db.aliases[alias] = canonical
+	}
+	db.daily.restore(snap.Daily, snap.Cycle)
+	for _, quote := range snap.Quotes {
+		sQuote := newSafeQuote(quote, db.resolveAuthor(quote.Author))
+		db.quotes[quote.ID] = sQuote
+		db.textIndex.add(quote.ID, quote.Text)
+		db.random.add(sQuote)
+		db.fingerprints.add(sQuote)
+		db.aliveIDs = append(db.aliveIDs, quote.ID)
+	}
+	for _, sQuote := range db.quotes {
+		db.indexTags(sQuote)
+	}
+	// индекс авторов строим по цитатам в порядке ID: отображаемым именем остается первое написание, как до перезапуска.
+	// Список авторов из старых снапшотов не читается
+	for _, quote := range snap.Quotes {
+		db.indexAuthor(db.quotes[quote.ID])
+	}
+}
+
+// копируем состояние под блокировкой на чтение: запись на диск идет уже без блокировки, поэтому
+// и писатели, и читатели ждут не дольше копирования в памяти. Читатели ждут только если за копированием
+// встал писатель: RWMutex не пускает новых читателей вперед ждущего писателя.
+// Удаления идут под той же блокировкой на чтение, поэтому номер записи берем до копирования:
+// удаление с меньшим номером уже держит блокировку цитаты и будет увидено,
+// а удаления с большим номером проиграются из журнала повторно.
+func (db *MemDB) capture() *snapshot {
+	db.RLock()
+	defer db.RUnlock()
+
+	snap := &snapshot{
+		NextID:  db.idGenerator.NextID(),
+		Quotes:  make([]entities.Quote, 0, len(db.quotes)),
+		Aliases: maps.Clone(db.aliases),
+	}
+	if db.wal != nil {
+		snap.LSN = db.wal.lastLSN()
+	}
+	snap.Daily, snap.Cycle = db.daily.capture()
+
+	for _, sQuote := range db.quotes {
+		sQuote.RLock()
+		if !sQuote.deleted {
+			snap.Quotes = append(snap.Quotes, *sQuote.Quote)
+		}
+		sQuote.RUnlock()
+	}
+
+	slices.SortFunc(snap.Quotes, func(a, b entities.Quote) int { return a.ID - b.ID })
+	return snap
+}
+
+// снимает снапшот, удаляет лишние старые и укорачивает журнал до старейшего сохраненного снапшота
+func (db *MemDB) Snapshot() error {
+	if db.snapshotDir == "" {
+		return errors.New("snapshots disabled")
+	}
+
+	db.snapshotMu.Lock()
+	defer db.snapshotMu.Unlock()
+
+	snap := db.capture()
+	if err := writeSnapshot(db.snapshotDir, snap); err != nil {
+		return err
+	}
+	db.snapshotLSN = snap.LSN
+
+	names, err := listSnapshots(db.snapshotDir)
+	if err != nil {
+		return err
+	}
+	if len(names) > snapshotsRetained {
+		for _, name := range names[snapshotsRetained:] {
+			if err := os.Remove(filepath.Join(db.snapshotDir, name)); err != nil {
+				return err
+			}
+		}
+		names = names[:snapshotsRetained]
+	}
+
+	if db.wal == nil {
+		return nil
+	}
+	oldest, err := readSnapshot(filepath.Join(db.snapshotDir, names[len(names)-1]))
+	if err != nil {
+		// без целого старшего снапшота опираемся только на только что записанный
+		oldest = snap
+	}
+	return db.wal.compact(oldest.LSN)
+}
+
+func (db *MemDB) snapshotLoop(interval time.Duration) {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-ticker.C:
+		}
+
+		if db.wal != nil && db.wal.lastLSN() == db.lastSnapshotLSN() {
+			continue
+		}
+		if err := db.Snapshot(); err != nil {
+			slog.Error("snapshot failed", "error", err.Error())
+		}
+	}
+}
+
+func (db *MemDB) lastSnapshotLSN() uint64 {
+	db.snapshotMu.Lock()
+	defer db.snapshotMu.Unlock()
+
+	return db.snapshotLSN
+}
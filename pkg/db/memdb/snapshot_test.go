@@ -0,0 +1,138 @@
+package memdb_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"sort"
+	"testing"
+)
+
+func TestSnapshotCompactsWAL(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "quotes.wal")
+	snapDir := filepath.Join(dir, "snapshots")
+	opts := []memdb.Option{memdb.WithWAL(walPath), memdb.WithSnapshots(snapDir, 0)}
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		_ = db.AddQuote(context.Background(), entities.Quote{Text: fmt.Sprint("Q", i), Author: "A1"})
+	}
+	_ = db.DeleteQuote(context.Background(), 0)
+
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "after first", Author: "A2"})
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// третий снапшот сдвигает старейший сохраненный и укорачивает журнал
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "tail", Author: "A2"})
+	before, _ := os.Stat(walPath)
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	after, _ := os.Stat(walPath)
+
+	_ = db.DeleteQuote(context.Background(), 1)
+	db.Close()
+
+	if after.Size() >= before.Size() {
+		t.Fatalf("expected wal to shrink after compaction: before %d, after %d", before.Size(), after.Size())
+	}
+
+	entries, _ := os.ReadDir(snapDir)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 retained snapshots, got %d", len(entries))
+	}
+
+	db = newTestDB(t, opts...)
+	quotes, _ := db.GetAllQuotes(context.Background())
+	if len(quotes) != 10 {
+		t.Fatalf("expected 10 quotes after restore, got %d", len(quotes))
+	}
+	for _, q := range quotes {
+		if q.ID == 0 || q.ID == 1 {
+			t.Fatalf("deleted quote %d restored", q.ID)
+		}
+	}
+
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "new", Author: "A3"})
+	quotes, _ = db.GetAuthorQuotes(context.Background(), "A3")
+	if len(quotes) != 1 || quotes[0].ID != 12 {
+		t.Fatalf("expected new quote with ID 12, got %v", quotes)
+	}
+}
+
+func TestSnapshotKeepsAuthorName(t *testing.T) {
+	dir := t.TempDir()
+	opts := []memdb.Option{memdb.WithWAL(filepath.Join(dir, "quotes.wal")), memdb.WithSnapshots(filepath.Join(dir, "snapshots"), 0)}
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	for i, author := range []string{"Confucius", "confucius", "CONFUCIUS"} {
+		_ = db.AddQuote(context.Background(), entities.Quote{Text: fmt.Sprint("Q", i), Author: author})
+	}
+	if err := db.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	db.Close()
+
+	// порядок обхода map случаен, поэтому перезапускаем несколько раз
+	for range 10 {
+		db, err = memdb.New(opts...)
+		if err != nil {
+			t.Fatalf("memdb.New failed: %v", err)
+		}
+		authors, _ := db.SuggestAuthors(context.Background(), "conf", 10)
+		db.Close()
+		if len(authors) != 1 || authors[0].Author != "Confucius" || authors[0].Quotes != 3 {
+			t.Fatalf("expected Confucius with 3 quotes after restore, got %v", authors)
+		}
+	}
+}
+
+func TestSnapshotFallbackToOlder(t *testing.T) {
+	dir := t.TempDir()
+	snapDir := filepath.Join(dir, "snapshots")
+	opts := []memdb.Option{memdb.WithWAL(filepath.Join(dir, "quotes.wal")), memdb.WithSnapshots(snapDir, 0)}
+
+	db, err := memdb.New(opts...)
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
+	_ = db.Snapshot()
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q2", Author: "A1"})
+	_ = db.Snapshot()
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q3", Author: "A1"})
+	db.Close()
+
+	// портим последний снапшот - должен загрузиться предыдущий и журнал после него
+	entries, _ := os.ReadDir(snapDir)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	latest := filepath.Join(snapDir, names[len(names)-1])
+	if err := os.WriteFile(latest, []byte("garbage"), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	db = newTestDB(t, opts...)
+	quotes, _ := db.GetAllQuotes(context.Background())
+	if len(quotes) != 3 {
+		t.Fatalf("expected 3 quotes after fallback, got %d", len(quotes))
+	}
+}
@@ -0,0 +1,66 @@
+package memdb
+
+import (
+	"net/url"
+	"quote_book/pkg/entities"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxSourceTitle = 500
+	maxSourcePage  = 32
+	maxSourceURL   = 2048
+)
+
+// источник в нормальной форме: поля без крайних пробелов, пустой источник - nil.
+// Год не может быть позже текущего года thisYear
+func normalizeSource(source *entities.Source, thisYear int) (*entities.Source, error) {
+	if source == nil {
+		return nil, nil
+	}
+
+	normalized := entities.Source{
+		Title: strings.Join(strings.Fields(source.Title), " "),
+		Year:  source.Year,
+		Page:  strings.TrimSpace(source.Page),
+		URL:   strings.TrimSpace(source.URL),
+	}
+	if normalized == (entities.Source{}) {
+		return nil, nil
+	}
+
+	switch {
+	case utf8.RuneCountInString(normalized.Title) > maxSourceTitle:
+		return nil, entities.Errorf(entities.ErrValidation, "source title is longer than %d characters", maxSourceTitle)
+	case normalized.Year > thisYear:
+		return nil, entities.Errorf(entities.ErrValidation, "source year %d is in the future", normalized.Year)
+	case utf8.RuneCountInString(normalized.Page) > maxSourcePage:
+		return nil, entities.Errorf(entities.ErrValidation, "source page is longer than %d characters", maxSourcePage)
+	case normalized.Page != "" && normalized.Title == "":
+		return nil, entities.Errorf(entities.ErrValidation, "source page without title")
+	case len(normalized.URL) > maxSourceURL:
+		return nil, entities.Errorf(entities.ErrValidation, "source url is longer than %d bytes", maxSourceURL)
+	}
+
+	if normalized.URL != "" {
+		u, err := url.Parse(normalized.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, entities.Errorf(entities.ErrValidation, "source url must be an absolute http(s) url")
+		}
+	}
+	return &normalized, nil
+}
+
+// статус цитаты; у цитат, записанных до появления статусов, его нет - они считаются непроверенными
+func attributionOf(quote *entities.Quote) entities.AttributionStatus {
+	if quote.Attribution == "" {
+		return entities.AttributionUnverified
+	}
+	return quote.Attribution
+}
+
+func matchesAttribution(quote *entities.Quote, statuses []entities.AttributionStatus) bool {
+	return len(statuses) == 0 || slices.Contains(statuses, attributionOf(quote))
+}
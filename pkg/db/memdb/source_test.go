@@ -0,0 +1,121 @@
+package memdb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceValidation(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	db := newTestDB(t, memdb.WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+
+	bad := map[string]entities.Quote{
+		"future year":     {Source: &entities.Source{Title: "Book", Year: 2025}},
+		"page only":       {Source: &entities.Source{Page: "12"}},
+		"long page":       {Source: &entities.Source{Title: "Book", Page: strings.Repeat("1", 33)}},
+		"long title":      {Source: &entities.Source{Title: strings.Repeat("к", 501)}},
+		"relative url":    {Source: &entities.Source{URL: "/wiki/Quote"}},
+		"ftp url":         {Source: &entities.Source{URL: "ftp://example.com/quote"}},
+		"no host":         {Source: &entities.Source{URL: "https://"}},
+		"unknown status":  {Attribution: "apocryphal"},
+		"uppercase state": {Attribution: "Verified"},
+	}
+	for name, quote := range bad {
+		quote.Text, quote.Author = "Q", "A"
+		if err := db.AddQuote(ctx, quote); !errors.Is(err, entities.ErrValidation) {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+
+	good := []entities.Quote{
+		{Text: "Q0", Author: "A", Source: &entities.Source{Title: "  Analects  ", Year: -475, Page: " xii ", URL: " https://example.com/analects "}},
+		{Text: "Q1", Author: "A", Source: &entities.Source{Title: " "}, Attribution: entities.AttributionDisputed},
+		{Text: "Q2", Author: "A", Source: &entities.Source{Year: 2024}},
+	}
+	for _, quote := range good {
+		if err := db.AddQuote(ctx, quote); err != nil {
+			t.Fatalf("AddQuote(%v) failed: %v", quote.Source, err)
+		}
+	}
+
+	want := map[int]string{
+		0: "&{Analects -475 xii https://example.com/analects} unverified",
+		1: "<nil> disputed",
+		2: "&{ 2024  } unverified",
+	}
+	for id, w := range want {
+		quote, _ := db.GetQuoteByID(ctx, id)
+		if got := fmt.Sprint(quote.Source, " ", quote.Attribution); got != w {
+			t.Errorf("quote %d: expected %q, got %q", id, w, got)
+		}
+	}
+
+	// неудачное изменение не должно задеть источник хранимой цитаты
+	_, err := db.UpdateQuote(ctx, 0, func(q *entities.Quote) error {
+		q.Source.Title = "Changed"
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Fatal("UpdateQuote should return update error")
+	}
+	if quote, _ := db.GetQuoteByID(ctx, 0); quote.Source.Title != "Analects" {
+		t.Fatalf("failed UpdateQuote changed stored source: %v", quote.Source)
+	}
+}
+
+func TestAttributionFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.wal")
+	ctx := context.Background()
+
+	db, err := memdb.New(memdb.WithWAL(path))
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q0", Author: "A", Attribution: entities.AttributionVerified})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q1", Author: "A", Attribution: entities.AttributionDisputed})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q2", Author: "A"})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q3", Author: "A", Attribution: entities.AttributionMisattributed})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q4", Author: "A", Attribution: entities.AttributionVerified})
+	_ = db.DeleteQuote(ctx, 4)
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db = newTestDB(t, memdb.WithWAL(path))
+	trusted := []entities.AttributionStatus{entities.AttributionVerified, entities.AttributionUnverified}
+
+	got := listIDs(t, db, entities.QuoteQuery{Attributions: trusted})
+	if fmt.Sprint(got) != "[0 2]" {
+		t.Fatalf("ListQuotes verified or unverified: expected [0 2], got %v", got)
+	}
+	got = listIDs(t, db, entities.QuoteQuery{Attributions: []entities.AttributionStatus{entities.AttributionDisputed}})
+	if fmt.Sprint(got) != "[1]" {
+		t.Fatalf("ListQuotes disputed: expected [1], got %v", got)
+	}
+
+	seen := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		quote, err := db.GetRandomQuote(ctx, entities.RandomQuery{Attributions: trusted})
+		if err != nil {
+			t.Fatalf("GetRandomQuote failed: %v", err)
+		}
+		seen[quote.ID] = true
+	}
+	if len(seen) != 2 || !seen[0] || !seen[2] {
+		t.Fatalf("GetRandomQuote verified or unverified: expected quotes 0 and 2, got %v", seen)
+	}
+
+	_ = db.DeleteQuote(ctx, 1)
+	_, err = db.GetRandomQuote(ctx, entities.RandomQuery{Attributions: []entities.AttributionStatus{entities.AttributionDisputed}})
+	if !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("GetRandomQuote with no matching quotes: expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package memdb
+
+import (
+	"cmp"
+	"context"
+	"quote_book/pkg/entities"
+	"slices"
+	"sync/atomic"
+)
+
+// цитаты с одним тегом; устроено как authorEntry
+type tagEntry struct {
+	quotes map[int]*safeQuote // включая удаленные, но еще не собранные GC
+	live   atomic.Int64
+	ids    liveIDs // при удалении меняется под randomMu
+}
+
+// теги цитаты в нормальной форме: без повторов, по алфавиту
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = entities.NormalizeTag(tag)
+		if tag == "" {
+			return nil, entities.Errorf(entities.ErrValidation, "blank tag")
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+// вызывается под блокировкой на запись или при восстановлении
+func (db *MemDB) indexTags(sQuote *safeQuote) {
+	for _, tag := range sQuote.Tags {
+		entry := db.tagIndex[tag]
+		if entry == nil {
+			entry = &tagEntry{quotes: make(map[int]*safeQuote)}
+			db.tagIndex[tag] = entry
+		}
+		entry.quotes[sQuote.ID] = sQuote
+		if !sQuote.deleted {
+			entry.live.Add(1)
+			entry.ids.add(sQuote.ID)
+		}
+	}
+}
+
+// вызывается под блокировкой на запись
+func (db *MemDB) unindexTags(sQuote *safeQuote) {
+	for _, tag := range sQuote.Tags {
+		entry := db.tagIndex[tag]
+		if entry == nil {
+			continue
+		}
+		delete(entry.quotes, sQuote.ID)
+		if !sQuote.deleted {
+			entry.live.Add(-1)
+			entry.ids.remove(sQuote.ID)
+		}
+		if len(entry.quotes) == 0 {
+			delete(db.tagIndex, tag)
+		}
+	}
+}
+
+// ID цитат со всеми (или, при any, хоть одним) тегами по возрастанию; вызывается под блокировкой на чтение
+func (db *MemDB) tagIDs(tags []string, mode entities.TagMode) []int {
+	entries := make([]*tagEntry, 0, len(tags))
+	for _, tag := range tags {
+		entry := db.tagIndex[entities.NormalizeTag(tag)]
+		if entry == nil {
+			if mode == entities.TagModeAny {
+				continue
+			}
+			return nil
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var ids []int
+	if mode == entities.TagModeAny {
+		seen := make(map[int]bool)
+		for _, entry := range entries {
+			for id := range entry.quotes {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	} else {
+		// перебираем самый короткий список, остальные только проверяем
+		shortest := slices.MinFunc(entries, func(a, b *tagEntry) int { return cmp.Compare(len(a.quotes), len(b.quotes)) })
+		for id := range shortest.quotes {
+			if hasAllTags(entries, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func hasAllTags(entries []*tagEntry, id int) bool {
+	for _, entry := range entries {
+		if _, ok := entry.quotes[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// пересечение упорядоченных списков ID
+func intersectIDs(a, b []int) []int {
+	ids := make([]int, 0, min(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			ids = append(ids, a[i])
+			i++
+			j++
+		}
+	}
+	return ids
+}
+
+// все теги с живыми цитатами: больше цитат - выше, при равенстве по алфавиту
+func (db *MemDB) ListTags(ctx context.Context) ([]entities.TagCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	tags := make([]entities.TagCount, 0, len(db.tagIndex))
+	for tag, entry := range db.tagIndex {
+		if live := int(entry.live.Load()); live > 0 {
+			tags = append(tags, entities.TagCount{Tag: tag, Quotes: live})
+		}
+	}
+	slices.SortFunc(tags, func(a, b entities.TagCount) int {
+		if c := cmp.Compare(b.Quotes, a.Quotes); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+	return tags, nil
+}
@@ -0,0 +1,88 @@
+package memdb_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"reflect"
+	"testing"
+)
+
+func listIDs(t *testing.T, db *memdb.MemDB, q entities.QuoteQuery) []int {
+	t.Helper()
+
+	if q.SortBy == "" {
+		q.SortBy = entities.SortByID
+	}
+	quotes, _, err := db.ListQuotes(context.Background(), q, nil, 100)
+	if err != nil {
+		t.Fatalf("ListQuotes failed: %v", err)
+	}
+	ids := make([]int, 0, len(quotes))
+	for _, quote := range quotes {
+		ids = append(ids, quote.ID)
+	}
+	return ids
+}
+
+func TestTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.wal")
+	ctx := context.Background()
+
+	db, err := memdb.New(memdb.WithWAL(path))
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q0", Author: "A", Tags: []string{" Love ", "war", "love"}})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q1", Author: "A", Tags: []string{"work"}})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q2", Author: "B", Tags: []string{"love"}})
+	_ = db.AddQuote(ctx, entities.Quote{Text: "Q3", Author: "B", Tags: []string{"war"}})
+
+	if err := db.AddQuote(ctx, entities.Quote{Text: "Q", Author: "A", Tags: []string{"  "}}); !errors.Is(err, entities.ErrValidation) {
+		t.Fatalf("blank tag: expected ErrValidation, got %v", err)
+	}
+
+	quote, _ := db.GetQuoteByID(ctx, 0)
+	if !reflect.DeepEqual(quote.Tags, []string{"love", "war"}) {
+		t.Fatalf("tags are not normalized: %v", quote.Tags)
+	}
+
+	check := func(db *memdb.MemDB) {
+		t.Helper()
+
+		all := entities.QuoteQuery{Tags: []string{"LOVE", "war"}, TagMode: entities.TagModeAll}
+		if ids := listIDs(t, db, all); !reflect.DeepEqual(ids, []int{0}) {
+			t.Fatalf("all tags: expected [0], got %v", ids)
+		}
+		anyTag := entities.QuoteQuery{Tags: []string{"work", "war", "missing"}, TagMode: entities.TagModeAny}
+		if ids := listIDs(t, db, anyTag); !reflect.DeepEqual(ids, []int{0, 1}) {
+			t.Fatalf("any tag: expected [0 1], got %v", ids)
+		}
+		byAuthor := entities.QuoteQuery{Author: "B", Tags: []string{"love"}, TagMode: entities.TagModeAll}
+		if ids := listIDs(t, db, byAuthor); !reflect.DeepEqual(ids, []int{2}) {
+			t.Fatalf("tag and author: expected [2], got %v", ids)
+		}
+
+		tags, _ := db.ListTags(ctx)
+		want := []entities.TagCount{{Tag: "love", Quotes: 2}, {Tag: "war", Quotes: 2}, {Tag: "work", Quotes: 1}}
+		if !reflect.DeepEqual(tags, want) {
+			t.Fatalf("ListTags expected %v, got %v", want, tags)
+		}
+	}
+
+	// Изменение тегов переиндексирует цитату, удаленные цитаты не считаются
+	_, _ = db.UpdateQuote(ctx, 1, func(q *entities.Quote) error {
+		q.Tags = append(q.Tags, "war")
+		return nil
+	})
+	_ = db.DeleteQuote(ctx, 3)
+	check(db)
+
+	// После перезапуска индекс тегов восстанавливается из журнала
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	check(newTestDB(t, memdb.WithWAL(path)))
+}
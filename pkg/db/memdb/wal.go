@@ -0,0 +1,356 @@
+package memdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"quote_book/pkg/entities"
+	"sync"
+	"time"
+)
+
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // fsync после каждой записи
+	SyncInterval SyncPolicy = "interval" // fsync в фоне раз в интервал
+	SyncNever    SyncPolicy = "never"    // сброс на диск остается на ОС
+)
+
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch p := SyncPolicy(s); p {
+	case SyncAlways, SyncInterval, SyncNever:
+		return p, nil
+	case "":
+		return SyncAlways, nil
+	default:
+		return "", fmt.Errorf("unknown sync policy %q", s)
+	}
+}
+
+// формат записи: [длина payload uint32][crc32c payload uint32][payload JSON]
+const (
+	walHeaderSize    = 8
+	walMaxRecordSize = 16 << 20
+)
+
+var (
+	crcTable         = crc32.MakeTable(crc32.Castagnoli)
+	errCorruptRecord = errors.New("corrupt wal record")
+)
+
+type walOp string
+
+const (
+	opAdd    walOp = "add"
+	opUpdate walOp = "update"
+	opDelete walOp = "delete"
+	opMerge  walOp = "merge"
+	opDaily  walOp = "daily"
+)
+
+type walRecord struct {
+	LSN   uint64          `json:"lsn"`
+	Op    walOp           `json:"op"`
+	Quote *entities.Quote `json:"quote,omitempty"`
+	ID    int             `json:"id,omitempty"`
+	From  string          `json:"from,omitempty"` // слияние авторов: кого
+	Into  string          `json:"into,omitempty"` // и в кого
+	At    *time.Time      `json:"at,omitempty"`   // слияние авторов: новое updated_at переписанных цитат
+	Day   string          `json:"day,omitempty"`  // цитата дня: день YYYY-MM-DD, цитата в ID
+}
+
+type wal struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	lsn    uint64 // номер последней записи
+	size   int64  // конец последней целой записи
+	policy SyncPolicy
+	dirty  bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func openWAL(path string, policy SyncPolicy, interval time.Duration) (*wal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &wal{path: path, file: file, policy: policy, done: make(chan struct{})}
+	if policy == SyncInterval {
+		l.wg.Add(1)
+		go l.syncLoop(interval)
+	}
+	return l, nil
+}
+
+// проигрывает журнал с начала, пропуская записи до fromLSN включительно (они уже есть в снапшоте);
+// битый или недописанный хвост после падения отрезается
+func (l *wal) replay(fromLSN uint64, apply func(walRecord)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(l.file)
+	var offset int64
+	l.lsn = fromLSN
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errCorruptRecord) || errors.Is(err, io.ErrUnexpectedEOF) {
+			slog.Warn("wal: discarding torn tail", "path", l.file.Name(), "offset", offset, "error", err.Error())
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += n
+
+		if rec.LSN == 0 { // записи без номера из журналов до появления снапшотов
+			rec.LSN = l.lsn + 1
+		}
+		if rec.LSN <= fromLSN {
+			continue
+		}
+		if rec.LSN > l.lsn+1 {
+			return fmt.Errorf("wal gap: expected record %d, got %d", l.lsn+1, rec.LSN)
+		}
+		l.lsn = rec.LSN
+		apply(rec)
+	}
+
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > offset {
+		if err := l.file.Truncate(offset); err != nil {
+			return err
+		}
+		if err := l.file.Sync(); err != nil {
+			return err
+		}
+	}
+	l.size = offset
+	_, err = l.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+func readRecord(r io.Reader) (walRecord, int64, error) {
+	var hdr [walHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return walRecord{}, 0, err
+	}
+
+	size := binary.LittleEndian.Uint32(hdr[0:4])
+	sum := binary.LittleEndian.Uint32(hdr[4:8])
+	if size == 0 || size > walMaxRecordSize {
+		return walRecord{}, 0, errCorruptRecord
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return walRecord{}, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != sum {
+		return walRecord{}, 0, errCorruptRecord
+	}
+
+	var rec walRecord
+	if err := json.Unmarshal(payload, &rec); err != nil || !rec.valid() {
+		return walRecord{}, 0, errCorruptRecord
+	}
+	return rec, int64(walHeaderSize + size), nil
+}
+
+func (rec walRecord) valid() bool {
+	switch rec.Op {
+	case opAdd, opUpdate:
+		return rec.Quote != nil
+	case opMerge:
+		return rec.From != "" && rec.Into != ""
+	case opDaily:
+		return rec.Day != ""
+	default:
+		return true
+	}
+}
+
+func encodeRecord(rec walRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	copy(buf[walHeaderSize:], payload)
+	return buf, nil
+}
+
+// запись целиком одним вызовом write, чтобы при падении процесса не терять уже принятые записи.
+// Запись, на которой вернулась ошибка, в журнале не остается: иначе после перезапуска проигралось бы
+// изменение, о котором клиенту ответили, что оно не удалось
+func (l *wal) append(rec walRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec.LSN = l.lsn + 1
+	buf, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	n, err := l.file.Write(buf)
+	if err != nil {
+		// отрезаем частично записанную запись, чтобы следующая легла за последней целой
+		if n > 0 {
+			l.rollback()
+		}
+		return err
+	}
+	if l.policy == SyncAlways {
+		if err := l.file.Sync(); err != nil {
+			l.rollback()
+			return err
+		}
+	} else {
+		l.dirty = true
+	}
+	l.lsn = rec.LSN
+	l.size += int64(n)
+	return nil
+}
+
+// отрезает все после последней принятой записи
+func (l *wal) rollback() {
+	l.file.Truncate(l.size)
+	l.file.Seek(l.size, io.SeekStart)
+}
+
+func (l *wal) lastLSN() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lsn
+}
+
+// удаляет из начала журнала записи до upto включительно.
+// Поиск границы идет без блокировки (уже записанная часть файла не меняется),
+// писатели ждут только копирования хвоста в новый файл.
+func (l *wal) compact(upto uint64) error {
+	l.mu.Lock()
+	file, size := l.file, l.size
+	l.mu.Unlock()
+
+	var cut int64
+	r := bufio.NewReader(io.NewSectionReader(file, 0, size))
+	for cut < size {
+		rec, n, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+		if rec.LSN > upto {
+			break
+		}
+		cut += n
+	}
+	if cut == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tmpPath := l.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, io.NewSectionReader(l.file, cut, l.size-cut)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := syncDir(filepath.Dir(l.path)); err != nil {
+		slog.Warn("wal: dir sync failed", "error", err.Error())
+	}
+
+	l.file.Close()
+	l.file = tmp
+	l.size -= cut
+	l.dirty = false
+	_, err = l.file.Seek(l.size, io.SeekStart)
+	return err
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+func (l *wal) syncLoop(interval time.Duration) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.dirty {
+				if err := l.file.Sync(); err != nil {
+					slog.Error("wal: sync failed", "error", err.Error())
+				} else {
+					l.dirty = false
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *wal) close() error {
+	close(l.done)
+	l.wg.Wait()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return errors.Join(l.file.Sync(), l.file.Close())
+}
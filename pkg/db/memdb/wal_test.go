@@ -0,0 +1,118 @@
+package memdb_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"quote_book/pkg/db/memdb"
+	"quote_book/pkg/entities"
+	"testing"
+)
+
+func TestWALReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.wal")
+
+	db, err := memdb.New(memdb.WithWAL(path))
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q2", Author: "A1"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q3", Author: "A2"})
+	_ = db.DeleteQuote(context.Background(), 1)
+	_, _ = db.UpdateQuote(context.Background(), 2, func(q *entities.Quote) error {
+		q.Author = "A1"
+		return nil
+	})
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// После перезапуска состояние восстанавливается из журнала
+	db = newTestDB(t, memdb.WithWAL(path))
+
+	quotes, _ := db.GetAllQuotes(context.Background())
+	if len(quotes) != 2 {
+		t.Fatalf("expected 2 quotes after replay, got %d", len(quotes))
+	}
+	for _, q := range quotes {
+		if q.ID == 1 {
+			t.Fatal("deleted quote restored after replay")
+		}
+		if q.ID == 2 && q.Author != "A1" {
+			t.Fatalf("expected update to be replayed, got author %q", q.Author)
+		}
+	}
+
+	// ID не должны повторяться
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q4", Author: "A2"})
+	quotes, _ = db.GetAuthorQuotes(context.Background(), "A2")
+	if len(quotes) != 1 || quotes[0].ID != 3 {
+		t.Fatalf("expected new quote to get ID 3, got %v", quotes)
+	}
+}
+
+func TestWALTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.wal")
+
+	db, err := memdb.New(memdb.WithWAL(path), memdb.WithSyncPolicy(memdb.SyncNever, 0))
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q2", Author: "A1"})
+	db.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+
+	// Имитируем падение посреди записи: отрезаем хвост последней записи
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+
+	db = newTestDB(t, memdb.WithWAL(path))
+	quotes, _ := db.GetAllQuotes(context.Background())
+	if len(quotes) != 1 || quotes[0].Text != "Q1" {
+		t.Fatalf("expected only first quote after torn tail, got %v", quotes)
+	}
+
+	// Журнал продолжает писаться после отрезанного хвоста
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q3", Author: "A1"})
+	db.Close()
+
+	db = newTestDB(t, memdb.WithWAL(path))
+	quotes, _ = db.GetAllQuotes(context.Background())
+	if len(quotes) != 2 {
+		t.Fatalf("expected 2 quotes after second replay, got %d", len(quotes))
+	}
+}
+
+func TestWALCorruptChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.wal")
+
+	db, err := memdb.New(memdb.WithWAL(path))
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A1"})
+	_ = db.AddQuote(context.Background(), entities.Quote{Text: "Q2", Author: "A1"})
+	db.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	data[len(data)-2] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	db = newTestDB(t, memdb.WithWAL(path))
+	quotes, _ := db.GetAllQuotes(context.Background())
+	if len(quotes) != 1 || quotes[0].Text != "Q1" {
+		t.Fatalf("expected only first quote after corrupt record, got %v", quotes)
+	}
+}
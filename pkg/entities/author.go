@@ -0,0 +1,39 @@
+package entities
+
+// автор и число его живых цитат
+type AuthorCount struct {
+	Author string `json:"author"`
+	Quotes int    `json:"quotes"`
+}
+
+type AuthorSortField string
+
+const (
+	AuthorSortByName  AuthorSortField = "name"
+	AuthorSortByCount AuthorSortField = "count"
+)
+
+func (f AuthorSortField) Valid() bool {
+	switch f {
+	case AuthorSortByName, AuthorSortByCount:
+		return true
+	}
+	return false
+}
+
+// порядок выдачи авторов; имена сравниваются без учета регистра
+type AuthorQuery struct {
+	SortBy AuthorSortField
+	Desc   bool
+}
+
+// ключ сортировки последнего отданного автора
+type AuthorPosition struct {
+	Name   string `json:"name"`
+	Quotes int    `json:"quotes,omitempty"`
+}
+
+type AuthorPage struct {
+	Authors    []AuthorCount `json:"authors"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
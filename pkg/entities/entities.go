@@ -1,7 +1,27 @@
 package entities
 
+import "time"
+
 type Quote struct {
-	ID     int    `json:"id"`
-	Author string `json:"author"`
-	Text   string `json:"quote"`
+	ID          int               `json:"id"`
+	Author      string            `json:"author"`
+	Text        string            `json:"quote"`
+	Tags        []string          `json:"tags,omitempty"` // без повторов, по алфавиту
+	Source      *Source           `json:"source,omitempty"`
+	Attribution AttributionStatus `json:"attribution,omitempty"` // без значения хранилище ставит unverified
+	Weight      int               `json:"weight,omitempty"`      // вес для случайного выбора, без значения - 1
+	CreatedAt   time.Time         `json:"created_at"`            // время ставит хранилище, присланные клиентом значения не учитываются
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// цитата дня: date - день в часовом поясе сервиса
+type DailyQuote struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Quote
 }
+
+// необязательные флаги добавления цитаты
+type AddOption string
+
+// добавить цитату, даже если у автора уже есть такая же
+const AllowDuplicate AddOption = "allow_duplicate"
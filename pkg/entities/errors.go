@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+)
+
+// виды ошибок предметной области; транспорт сопоставляет их со статусами ответа
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrValidation  = errors.New("validation failed")
+	ErrConflict    = errors.New("conflict")
+	ErrUnavailable = errors.New("storage unavailable")
+)
+
+// ошибка с видом и описанием, которое можно показать клиенту
+type Error struct {
+	Kind   error
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func Errorf(kind error, format string, args ...any) error {
+	return &Error{Kind: kind, Detail: fmt.Sprintf(format, args...)}
+}
+
+// у автора уже есть такая цитата
+type DuplicateError struct {
+	ExistingID int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%v: duplicate of quote %d", ErrConflict, e.ExistingID)
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrConflict
+}
@@ -0,0 +1,12 @@
+package entities
+
+// параметры страницы, как их передает клиент; курсор непрозрачен для клиента
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+type QuotePage struct {
+	Quotes     []Quote `json:"quotes"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,75 @@
+package entities
+
+import "time"
+
+type SortField string
+
+const (
+	SortByID      SortField = "id"
+	SortByAuthor  SortField = "author"
+	SortByLength  SortField = "length"
+	SortByCreated SortField = "created"
+)
+
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByID, SortByAuthor, SortByLength, SortByCreated:
+		return true
+	}
+	return false
+}
+
+// фильтры и порядок выдачи цитат; nil в границах - без ограничения, границы включительно,
+// кроме CreatedBefore: промежуток времени полуоткрытый, чтобы соседние промежутки не пересекались
+type QuoteQuery struct {
+	Author              string
+	Tags                []string
+	TagMode             TagMode
+	SortBy              SortField
+	Desc                bool
+	MinID               *int
+	MaxID               *int
+	MinLength           *int // длина текста в символах
+	MaxLength           *int
+	CreatedAfter        *time.Time
+	CreatedBefore       *time.Time
+	Attributions        []AttributionStatus // любой из перечисленных
+	ExcludeAttributions []AttributionStatus // ни один из перечисленных
+}
+
+// как выбирается случайная цитата
+type RandomMode string
+
+const (
+	RandomUniform  RandomMode = "uniform"  // все цитаты равновероятны
+	RandomByAuthor RandomMode = "author"   // сначала равновероятно автор, потом его цитата
+	RandomWeighted RandomMode = "weighted" // вероятность пропорциональна весу цитаты
+)
+
+func (m RandomMode) Valid() bool {
+	switch m {
+	case RandomUniform, RandomByAuthor, RandomWeighted:
+		return true
+	}
+	return false
+}
+
+// фильтры случайной цитаты, как в QuoteQuery; пустые - без ограничения
+type RandomQuery struct {
+	Author              string
+	Tags                []string // цитата должна иметь все теги
+	MaxLength           *int
+	Attributions        []AttributionStatus
+	ExcludeAttributions []AttributionStatus
+	Seed                string // непустой - выбор воспроизводим: тот же seed на тех же данных дает те же цитаты
+	Bag                 string // токен клиента: цитаты не повторяются, пока клиент не увидит все подходящие
+	Mode                RandomMode
+}
+
+// ключ сортировки последней отданной цитаты, с него продолжается следующая страница
+type Position struct {
+	Author  string    `json:"author,omitempty"`
+	Length  int       `json:"length,omitempty"`
+	Created time.Time `json:"created"`
+	ID      int       `json:"id"`
+}
@@ -0,0 +1,6 @@
+package entities
+
+type SearchResult struct {
+	Quote Quote   `json:"quote"`
+	Score float64 `json:"score"`
+}
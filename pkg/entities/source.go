@@ -0,0 +1,30 @@
+package entities
+
+// откуда взята цитата; все поля необязательны
+type Source struct {
+	Title string `json:"title,omitempty"` // произведение, статья, выступление
+	Year  int    `json:"year,omitempty"`  // отрицательный - до нашей эры
+	Page  string `json:"page,omitempty"`  // строкой: бывают "xii" и "12-14"
+	URL   string `json:"url,omitempty"`
+}
+
+// насколько достоверно цитата приписана автору
+type AttributionStatus string
+
+const (
+	AttributionUnverified    AttributionStatus = "unverified" // по умолчанию: еще не проверяли
+	AttributionVerified      AttributionStatus = "verified"
+	AttributionDisputed      AttributionStatus = "disputed"      // авторство под сомнением
+	AttributionMisattributed AttributionStatus = "misattributed" // известно, что автор другой
+)
+
+// все статусы авторства, в порядке от достоверного к недостоверному
+var AttributionStatuses = []AttributionStatus{AttributionVerified, AttributionUnverified, AttributionDisputed, AttributionMisattributed}
+
+func (s AttributionStatus) Valid() bool {
+	switch s {
+	case AttributionUnverified, AttributionVerified, AttributionDisputed, AttributionMisattributed:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package entities
+
+import "strings"
+
+// тег темы цитаты ("love", "work"); хранится в нижнем регистре без лишних пробелов
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// как сочетать несколько тегов в фильтре
+type TagMode string
+
+const (
+	TagModeAll TagMode = "all" // цитата должна иметь все теги
+	TagModeAny TagMode = "any" // хотя бы один
+)
+
+func (m TagMode) Valid() bool {
+	return m == TagModeAll || m == TagModeAny
+}
+
+// тег и число живых цитат с ним
+type TagCount struct {
+	Tag    string `json:"tag"`
+	Quotes int    `json:"quotes"`
+}
@@ -1,10 +1,26 @@
 package service
 
-import "quote_book/pkg/entities"
+import (
+	"context"
+	"quote_book/pkg/entities"
+	"time"
+)
 
 type QuoteService interface {
-	AddQuote(quote entities.Quote) error
-	GetQuotes(author string) ([]entities.Quote, error)
-	GetRandomQuote() (entities.Quote, error)
-	DeleteQuote(id int) error
+	AddQuote(ctx context.Context, quote entities.Quote, opts ...entities.AddOption) error
+	GetQuotes(ctx context.Context, query entities.QuoteQuery, page entities.PageRequest) (entities.QuotePage, error)
+	GetQuoteByID(ctx context.Context, id int) (entities.Quote, error)
+	SearchQuotes(ctx context.Context, text string, limit int) ([]entities.SearchResult, error)
+	GetAuthors(ctx context.Context, query entities.AuthorQuery, page entities.PageRequest) (entities.AuthorPage, error)
+	SuggestAuthors(ctx context.Context, prefix string, limit int) ([]entities.AuthorCount, error)
+	MergeAuthors(ctx context.Context, from, into string) (entities.AuthorCount, error)
+	GetRandomQuote(ctx context.Context, query entities.RandomQuery) (entities.Quote, error)
+	GetRandomQuotes(ctx context.Context, query entities.RandomQuery, count int) ([]entities.Quote, error)
+	GetDailyQuote(ctx context.Context, date *time.Time) (entities.DailyQuote, error)
+	UpdateQuote(ctx context.Context, quote entities.Quote) (entities.Quote, error)
+	PatchQuote(ctx context.Context, id int, patch []byte) (entities.Quote, error)
+	DeleteQuote(ctx context.Context, id int) error
+	AddTag(ctx context.Context, id int, tag string) (entities.Quote, error)
+	RemoveTag(ctx context.Context, id int, tag string) (entities.Quote, error)
+	GetTags(ctx context.Context) ([]entities.TagCount, error)
 }
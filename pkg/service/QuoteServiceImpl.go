@@ -1,56 +1,386 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
+	"fmt"
 	"quote_book/pkg/db"
 	"quote_book/pkg/entities"
+	"quote_book/pkg/utils"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type quoteServiceImpl struct {
-	db db.DB
+	db       db.DB
+	location *time.Location // часовой пояс цитаты дня
+	clock    func() time.Time
 }
 
-func NewQuoteService(db db.DB) *quoteServiceImpl {
-	return &quoteServiceImpl{db: db}
+func NewQuoteService(db db.DB, opts ...Option) *quoteServiceImpl {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &quoteServiceImpl{db: db, location: o.location, clock: o.clock}
 }
 
-func (qs *quoteServiceImpl) AddQuote(quote entities.Quote) error {
-	err := qs.db.AddQuote(quote)
+// дубль цитаты того же автора отклоняется, если не передан entities.AllowDuplicate
+func (qs *quoteServiceImpl) AddQuote(ctx context.Context, quote entities.Quote, opts ...entities.AddOption) error {
+	err := qs.db.AddQuote(ctx, quote, opts...)
 	if err != nil {
-		return errors.Join(errors.New("service AddQuote: "), err)
+		return fmt.Errorf("service AddQuote: %w", err)
 	}
 	return nil
 }
 
-func (qs *quoteServiceImpl) GetQuotes(author string) ([]entities.Quote, error) {
-	var quotes []entities.Quote
-	var err error
+// страница цитат по запросу; без сортировки - по возрастанию ID
+func (qs *quoteServiceImpl) GetQuotes(ctx context.Context, query entities.QuoteQuery, page entities.PageRequest) (entities.QuotePage, error) {
+	if err := validateQuery(&query); err != nil {
+		return entities.QuotePage{}, fmt.Errorf("service GetQuotes: %w", err)
+	}
+	limit, err := checkLimit(page.Limit, DefaultPageLimit, MaxPageLimit)
+	if err != nil {
+		return entities.QuotePage{}, fmt.Errorf("service GetQuotes: %w", err)
+	}
+	after, err := decodeCursor(page.Cursor, query)
+	if err != nil {
+		return entities.QuotePage{}, fmt.Errorf("service GetQuotes: %w", err)
+	}
 
-	if author == "" {
-		quotes, err = qs.db.GetAllQuotes()
-	} else {
-		quotes, err = qs.db.GetAuthorQuotes(author)
+	quotes, more, err := qs.db.ListQuotes(ctx, query, after, limit)
+	if err != nil {
+		return entities.QuotePage{}, fmt.Errorf("service GetQuotes: %w", err)
+	}
+
+	result := entities.QuotePage{Quotes: quotes}
+	if more {
+		last := quotes[len(quotes)-1]
+		result.NextCursor = encodeCursor(cursor{
+			SortBy: query.SortBy,
+			Desc:   query.Desc,
+			After:  entities.Position{Author: last.Author, Length: utf8.RuneCountInString(last.Text), Created: last.CreatedAt, ID: last.ID},
+		})
+	}
+	return result, nil
+}
+
+func validateQuery(query *entities.QuoteQuery) error {
+	if query.SortBy == "" {
+		query.SortBy = entities.SortByID
+	}
+	if !query.SortBy.Valid() {
+		return entities.Errorf(entities.ErrValidation, "unknown sort field %q", query.SortBy)
+	}
+	if query.TagMode == "" {
+		query.TagMode = entities.TagModeAll
+	}
+	if !query.TagMode.Valid() {
+		return entities.Errorf(entities.ErrValidation, "unknown tag mode %q", query.TagMode)
+	}
+	if query.MinID != nil && query.MaxID != nil && *query.MinID > *query.MaxID {
+		return entities.Errorf(entities.ErrValidation, "min_id is greater than max_id")
+	}
+	if query.MinLength != nil && query.MaxLength != nil && *query.MinLength > *query.MaxLength {
+		return entities.Errorf(entities.ErrValidation, "min_length is greater than max_length")
+	}
+	if query.CreatedAfter != nil && query.CreatedBefore != nil && query.CreatedAfter.After(*query.CreatedBefore) {
+		return entities.Errorf(entities.ErrValidation, "created_after is later than created_before")
+	}
+	statuses, err := attributionFilter(query.Attributions, query.ExcludeAttributions)
+	if err != nil {
+		return err
+	}
+	query.Attributions, query.ExcludeAttributions = statuses, nil
+	return nil
+}
+
+// исключения переводим в список подходящих статусов: хранилище фильтрует только по нему.
+// Без включаемых статусов исключения вычитаются из всех
+func attributionFilter(include, exclude []entities.AttributionStatus) ([]entities.AttributionStatus, error) {
+	for _, status := range slices.Concat(include, exclude) {
+		if !status.Valid() {
+			return nil, entities.Errorf(entities.ErrValidation, "unknown attribution status %q", status)
+		}
+	}
+	if len(exclude) == 0 {
+		return include, nil
+	}
+
+	if len(include) == 0 {
+		include = entities.AttributionStatuses
+	}
+	statuses := make([]entities.AttributionStatus, 0, len(include))
+	for _, status := range include {
+		if !slices.Contains(exclude, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) == 0 {
+		return nil, entities.Errorf(entities.ErrValidation, "attribution filter excludes every status")
+	}
+	return statuses, nil
+}
+
+func (qs *quoteServiceImpl) GetQuoteByID(ctx context.Context, id int) (entities.Quote, error) {
+	quote, err := qs.db.GetQuoteByID(ctx, id)
+	if err != nil {
+		return entities.Quote{}, fmt.Errorf("service GetQuoteByID: %w", err)
+	}
+
+	return quote, nil
+}
+
+// полнотекстовый поиск: все слова обязательны, фразы в кавычках, лучшие по релевантности первыми
+func (qs *quoteServiceImpl) SearchQuotes(ctx context.Context, text string, limit int) ([]entities.SearchResult, error) {
+	limit, err := checkLimit(limit, DefaultSearchLimit, MaxSearchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("service SearchQuotes: %w", err)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("service SearchQuotes: %w", entities.Errorf(entities.ErrValidation, "empty search query"))
+	}
+
+	results, err := qs.db.SearchQuotes(ctx, text, limit)
+	if err != nil {
+		return nil, fmt.Errorf("service SearchQuotes: %w", err)
+	}
+
+	return results, nil
+}
+
+// страница авторов с числом живых цитат; без сортировки - по имени
+func (qs *quoteServiceImpl) GetAuthors(ctx context.Context, query entities.AuthorQuery, page entities.PageRequest) (entities.AuthorPage, error) {
+	if query.SortBy == "" {
+		query.SortBy = entities.AuthorSortByName
+	}
+	if !query.SortBy.Valid() {
+		return entities.AuthorPage{}, fmt.Errorf("service GetAuthors: %w", entities.Errorf(entities.ErrValidation, "unknown sort field %q", query.SortBy))
+	}
+	limit, err := checkLimit(page.Limit, DefaultPageLimit, MaxPageLimit)
+	if err != nil {
+		return entities.AuthorPage{}, fmt.Errorf("service GetAuthors: %w", err)
+	}
+	after, err := decodeAuthorCursor(page.Cursor, query)
+	if err != nil {
+		return entities.AuthorPage{}, fmt.Errorf("service GetAuthors: %w", err)
+	}
+
+	authors, more, err := qs.db.ListAuthors(ctx, query, after, limit)
+	if err != nil {
+		return entities.AuthorPage{}, fmt.Errorf("service GetAuthors: %w", err)
+	}
+
+	result := entities.AuthorPage{Authors: authors}
+	if more {
+		last := authors[len(authors)-1]
+		result.NextCursor = encodeCursor(authorCursor{
+			SortBy: query.SortBy,
+			Desc:   query.Desc,
+			After:  entities.AuthorPosition{Name: last.Author, Quotes: last.Quotes},
+		})
+	}
+	return result, nil
+}
+
+// авторы, одно из слов имени которых начинается с prefix, - для подсказок при вводе
+func (qs *quoteServiceImpl) SuggestAuthors(ctx context.Context, prefix string, limit int) ([]entities.AuthorCount, error) {
+	limit, err := checkLimit(limit, DefaultSuggestLimit, MaxSuggestLimit)
+	if err != nil {
+		return nil, fmt.Errorf("service SuggestAuthors: %w", err)
+	}
+	if strings.TrimSpace(prefix) == "" {
+		return nil, fmt.Errorf("service SuggestAuthors: %w", entities.Errorf(entities.ErrValidation, "empty prefix"))
+	}
+
+	authors, err := qs.db.SuggestAuthors(ctx, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("service SuggestAuthors: %w", err)
+	}
+
+	return authors, nil
+}
+
+// все цитаты автора from переходят к автору into, from становится его псевдонимом
+func (qs *quoteServiceImpl) MergeAuthors(ctx context.Context, from, into string) (entities.AuthorCount, error) {
+	if strings.TrimSpace(from) == "" || strings.TrimSpace(into) == "" {
+		return entities.AuthorCount{}, fmt.Errorf("service MergeAuthors: %w", entities.Errorf(entities.ErrValidation, "empty author"))
 	}
+
+	author, err := qs.db.MergeAuthors(ctx, from, into)
 	if err != nil {
-		return []entities.Quote{}, errors.Join(errors.New("service GetQuotes: "), err)
+		return entities.AuthorCount{}, fmt.Errorf("service MergeAuthors: %w", err)
+	}
+
+	return author, nil
+}
+
+// случайная цитата среди подходящих под query
+func (qs *quoteServiceImpl) GetRandomQuote(ctx context.Context, query entities.RandomQuery) (entities.Quote, error) {
+	if err := validateRandomQuery(&query); err != nil {
+		return entities.Quote{}, fmt.Errorf("service GetRandomQuote: %w", err)
+	}
+
+	quotes, err := qs.db.GetRandomQuote(ctx, query)
+	if err != nil {
+		return entities.Quote{}, fmt.Errorf("service GetRandomQuote: %w", err)
 	}
 
 	return quotes, nil
 }
 
-func (qs *quoteServiceImpl) GetRandomQuote() (entities.Quote, error) {
-	quotes, err := qs.db.GetRandomQuote()
+func validateRandomQuery(query *entities.RandomQuery) error {
+	if query.Seed != "" && query.Bag != "" {
+		return entities.Errorf(entities.ErrValidation, "seed and bag cannot be used together")
+	}
+	if len(query.Bag) > MaxBagLength {
+		return entities.Errorf(entities.ErrValidation, "bag is longer than %d bytes", MaxBagLength)
+	}
+	if query.Mode != "" && !query.Mode.Valid() {
+		return entities.Errorf(entities.ErrValidation, "unknown random mode %q", query.Mode)
+	}
+	if query.Bag != "" && query.Mode != "" && query.Mode != entities.RandomUniform {
+		return entities.Errorf(entities.ErrValidation, "bag works only with uniform mode")
+	}
+	statuses, err := attributionFilter(query.Attributions, query.ExcludeAttributions)
 	if err != nil {
-		return entities.Quote{}, errors.Join(errors.New("service GetRandomQuote: "), err)
+		return err
+	}
+	query.Attributions, query.ExcludeAttributions = statuses, nil
+	return nil
+}
+
+// count разных случайных цитат среди подходящих под query; если подходящих меньше - все
+func (qs *quoteServiceImpl) GetRandomQuotes(ctx context.Context, query entities.RandomQuery, count int) ([]entities.Quote, error) {
+	if count < 1 || count > MaxRandomCount {
+		return nil, fmt.Errorf("service GetRandomQuotes: %w", entities.Errorf(entities.ErrValidation, "count must be between 1 and %d", MaxRandomCount))
+	}
+	if err := validateRandomQuery(&query); err != nil {
+		return nil, fmt.Errorf("service GetRandomQuotes: %w", err)
+	}
+
+	quotes, err := qs.db.GetRandomQuotes(ctx, query, count)
+	if err != nil {
+		return nil, fmt.Errorf("service GetRandomQuotes: %w", err)
 	}
 
 	return quotes, nil
 }
 
-func (qs *quoteServiceImpl) DeleteQuote(id int) error {
-	err := qs.db.DeleteQuote(id)
+// цитата дня date (без date - сегодняшнего) в часовом поясе сервиса; от date берется только календарная дата.
+// Сегодняшняя цитата выбирается при первом запросе за день, за прошедшие дни отдается уже выбранная
+func (qs *quoteServiceImpl) GetDailyQuote(ctx context.Context, date *time.Time) (entities.DailyQuote, error) {
+	today := qs.clock().In(qs.location).Format(time.DateOnly)
+	day := today
+	if date != nil {
+		day = date.Format(time.DateOnly)
+	}
+	// у дат с четырехзначным годом строковый порядок совпадает с календарным
+	if day > today {
+		return entities.DailyQuote{}, fmt.Errorf("service GetDailyQuote: %w", entities.Errorf(entities.ErrValidation, "date %s is in the future", day))
+	}
+
+	var quote entities.Quote
+	var err error
+	if day == today {
+		quote, err = qs.db.PickDailyQuote(ctx, day)
+	} else {
+		quote, err = qs.db.GetDailyQuote(ctx, day)
+	}
+	if err != nil {
+		return entities.DailyQuote{}, fmt.Errorf("service GetDailyQuote: %w", err)
+	}
+
+	return entities.DailyQuote{Date: day, Quote: quote}, nil
+}
+
+// полная замена цитаты с ID quote.ID
+func (qs *quoteServiceImpl) UpdateQuote(ctx context.Context, quote entities.Quote) (entities.Quote, error) {
+	updated, err := qs.db.UpdateQuote(ctx, quote.ID, func(q *entities.Quote) error {
+		*q = quote
+		return nil
+	})
+	if err != nil {
+		return entities.Quote{}, fmt.Errorf("service UpdateQuote: %w", err)
+	}
+
+	return updated, nil
+}
+
+// частичное изменение через JSON merge patch (RFC 7396)
+func (qs *quoteServiceImpl) PatchQuote(ctx context.Context, id int, patch []byte) (entities.Quote, error) {
+	updated, err := qs.db.UpdateQuote(ctx, id, func(q *entities.Quote) error {
+		doc, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+		merged, err := utils.MergePatch(doc, patch)
+		if err != nil {
+			return entities.Errorf(entities.ErrValidation, "bad patch: %v", err)
+		}
+
+		var patched entities.Quote
+		if err := json.Unmarshal(merged, &patched); err != nil {
+			return entities.Errorf(entities.ErrValidation, "bad patch: %v", err)
+		}
+		*q = patched
+		return nil
+	})
+	if err != nil {
+		return entities.Quote{}, fmt.Errorf("service PatchQuote: %w", err)
+	}
+
+	return updated, nil
+}
+
+// теги меняются через UpdateQuote, чтобы изменение было атомарным; повторное добавление ничего не меняет
+func (qs *quoteServiceImpl) AddTag(ctx context.Context, id int, tag string) (entities.Quote, error) {
+	tag = entities.NormalizeTag(tag)
+	if tag == "" {
+		return entities.Quote{}, fmt.Errorf("service AddTag: %w", entities.Errorf(entities.ErrValidation, "blank tag"))
+	}
+
+	updated, err := qs.db.UpdateQuote(ctx, id, func(q *entities.Quote) error {
+		q.Tags = append(q.Tags, tag)
+		return nil
+	})
+	if err != nil {
+		return entities.Quote{}, fmt.Errorf("service AddTag: %w", err)
+	}
+
+	return updated, nil
+}
+
+// удаление отсутствующего тега ничего не меняет
+func (qs *quoteServiceImpl) RemoveTag(ctx context.Context, id int, tag string) (entities.Quote, error) {
+	tag = entities.NormalizeTag(tag)
+
+	updated, err := qs.db.UpdateQuote(ctx, id, func(q *entities.Quote) error {
+		q.Tags = slices.DeleteFunc(q.Tags, func(t string) bool { return t == tag })
+		return nil
+	})
+	if err != nil {
+		return entities.Quote{}, fmt.Errorf("service RemoveTag: %w", err)
+	}
+
+	return updated, nil
+}
+
+func (qs *quoteServiceImpl) GetTags(ctx context.Context) ([]entities.TagCount, error) {
+	tags, err := qs.db.ListTags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service GetTags: %w", err)
+	}
+
+	return tags, nil
+}
+
+func (qs *quoteServiceImpl) DeleteQuote(ctx context.Context, id int) error {
+	err := qs.db.DeleteQuote(ctx, id)
 	if err != nil {
-		return errors.Join(errors.New("service DeleteQuote: "), qs.db.DeleteQuote(id))
+		return fmt.Errorf("service DeleteQuote: %w", err)
 	}
 	return err
 }
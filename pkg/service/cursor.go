@@ -0,0 +1,95 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"quote_book/pkg/entities"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	DefaultSuggestLimit = 10
+	MaxSuggestLimit     = 50
+
+	MaxRandomCount = 100
+	MaxBagLength   = 128
+)
+
+// содержимое курсора скрыто от клиента, чтобы его можно было менять без поломки клиентов
+// курсор привязан к порядку выдачи: с другим порядком позиция не имеет смысла
+type cursor struct {
+	SortBy entities.SortField `json:"sort"`
+	Desc   bool               `json:"desc,omitempty"`
+	After  entities.Position  `json:"after"`
+}
+
+// курсор списка авторов
+type authorCursor struct {
+	SortBy entities.AuthorSortField `json:"sort"`
+	Desc   bool                     `json:"desc,omitempty"`
+	After  entities.AuthorPosition  `json:"after"`
+}
+
+func encodeCursor(c any) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func unmarshalCursor(s string, c any) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return entities.Errorf(entities.ErrValidation, "bad cursor")
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return entities.Errorf(entities.ErrValidation, "bad cursor")
+	}
+	return nil
+}
+
+// позиция, с которой продолжать выдачу для запроса q; nil - с начала
+func decodeCursor(s string, q entities.QuoteQuery) (*entities.Position, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var c cursor
+	if err := unmarshalCursor(s, &c); err != nil {
+		return nil, err
+	}
+	if c.SortBy != q.SortBy || c.Desc != q.Desc {
+		return nil, entities.Errorf(entities.ErrValidation, "cursor belongs to another sort order")
+	}
+	return &c.After, nil
+}
+
+func decodeAuthorCursor(s string, q entities.AuthorQuery) (*entities.AuthorPosition, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var c authorCursor
+	if err := unmarshalCursor(s, &c); err != nil {
+		return nil, err
+	}
+	if c.SortBy != q.SortBy || c.Desc != q.Desc {
+		return nil, entities.Errorf(entities.ErrValidation, "cursor belongs to another sort order")
+	}
+	return &c.After, nil
+}
+
+// 0 - значение по умолчанию
+func checkLimit(limit, defaultLimit, maxLimit int) (int, error) {
+	switch {
+	case limit == 0:
+		return defaultLimit, nil
+	case limit < 0 || limit > maxLimit:
+		return 0, entities.Errorf(entities.ErrValidation, "limit must be between 1 and %d", maxLimit)
+	default:
+		return limit, nil
+	}
+}
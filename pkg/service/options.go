@@ -0,0 +1,37 @@
+package service
+
+import "time"
+
+type options struct {
+	location *time.Location
+	clock    func() time.Time
+}
+
+type Option func(*options)
+
+// WithLocation задает часовой пояс, в котором считаются дни цитаты дня.
+// По умолчанию - UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) {
+		if loc != nil {
+			o.location = loc
+		}
+	}
+}
+
+// WithClock задает часы, по которым определяется текущий день.
+// По умолчанию - time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		if now != nil {
+			o.clock = now
+		}
+	}
+}
+
+func defaultOptions() options {
+	return options{
+		location: time.UTC,
+		clock:    time.Now,
+	}
+}
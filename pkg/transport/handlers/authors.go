@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"quote_book/pkg/entities"
+	"quote_book/pkg/service"
+
+	"github.com/gorilla/mux"
+)
+
+func NewGetAuthorsHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "GetAuthorsHandler")
+
+		values := r.URL.Query()
+		desc, page, err := orderAndPage(values)
+		if err != nil {
+			logger.Error("Not valid query", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		query := entities.AuthorQuery{SortBy: entities.AuthorSortField(values.Get("sort")), Desc: desc}
+
+		authors, err := qs.GetAuthors(r.Context(), query, page)
+		if err != nil {
+			serviceError(w, r, logger, err, "getting authors error")
+			return
+		}
+
+		if authors.NextCursor != "" {
+			w.Header().Set("Link", nextLink(r, authors.NextCursor))
+		}
+
+		logger.Info("Authors recived", "count", len(authors.Authors))
+		writeJSON(w, r, logger, authors)
+	}
+}
+
+func NewSuggestAuthorsHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "SuggestAuthorsHandler")
+
+		values := r.URL.Query()
+		limit, err := intParam(values, "limit")
+		if err != nil {
+			logger.Error("Not valid limit", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		authors, err := qs.SuggestAuthors(r.Context(), values.Get("prefix"), valueOr(limit, 0))
+		if err != nil {
+			serviceError(w, r, logger, err, "suggesting authors error")
+			return
+		}
+
+		logger.Info("Authors suggested", "count", len(authors))
+		writeJSON(w, r, logger, struct {
+			Authors []entities.AuthorCount `json:"authors"`
+		}{authors})
+	}
+}
+
+// административная операция: все цитаты автора из пути переходят к автору из тела {"into": "..."}
+func NewMergeAuthorsHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "MergeAuthorsHandler")
+
+		var req struct {
+			Into string `json:"into"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("JSON parsing failed", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, "bad json")
+			return
+		}
+
+		from := mux.Vars(r)["name"]
+		author, err := qs.MergeAuthors(r.Context(), from, req.Into)
+		if err != nil {
+			serviceError(w, r, logger, err, "authors not merged")
+			return
+		}
+
+		logger.Info("Authors merged", "from", from, "into", author.Author)
+		writeJSON(w, r, logger, author)
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"quote_book/pkg/service"
+	"time"
+)
+
+// цитата дня; ?date=YYYY-MM-DD - цитата прошедшего дня
+func NewGetDailyQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "GetDailyQuoteHandler")
+
+		var date *time.Time
+		if raw := r.URL.Query().Get("date"); raw != "" {
+			parsed, err := time.Parse(time.DateOnly, raw)
+			if err != nil {
+				logger.Error("Not valid query", "error", err.Error())
+				problem(w, r, http.StatusBadRequest, "not valid date")
+				return
+			}
+			date = &parsed
+		}
+
+		quote, err := qs.GetDailyQuote(r.Context(), date)
+		if err != nil {
+			serviceError(w, r, logger, err, "quote getting error")
+			return
+		}
+
+		logger.Info("Quote recived", "date", quote.Date)
+		writeJSON(w, r, logger, quote)
+	}
+}
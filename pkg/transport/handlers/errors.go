@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"quote_book/pkg/entities"
+)
+
+// единое сопоставление ошибок предметной области со статусами ответа и типами ошибок
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, entities.ErrValidation):
+		return http.StatusBadRequest, problemValidation
+	case errors.Is(err, entities.ErrNotFound):
+		return http.StatusNotFound, problemNotFound
+	case errors.Is(err, entities.ErrConflict):
+		return http.StatusConflict, problemConflict
+	case errors.Is(err, entities.ErrUnavailable),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, problemUnavailable
+	default:
+		return http.StatusInternalServerError, problemInternal
+	}
+}
+
+// клиенту отдаем описание только для его собственных ошибок (4xx), внутренние детали остаются в логе
+func serviceError(w http.ResponseWriter, r *http.Request, logger slog.Logger, err error, message string) {
+	status, problemType := errorStatus(err)
+	if status >= http.StatusInternalServerError {
+		logger.Error(message, "error", err.Error(), "status", status)
+		writeProblem(w, r, Problem{Type: problemType, Status: status, Detail: message})
+		return
+	}
+
+	logger.Info(message, "error", err.Error(), "status", status)
+	p := Problem{Type: problemType, Status: status, Detail: message}
+	var domainErr *entities.Error
+	var duplicate *entities.DuplicateError
+	switch {
+	case errors.As(err, &domainErr):
+		p.Detail = domainErr.Detail
+	case errors.As(err, &duplicate):
+		p.Detail = fmt.Sprintf("duplicate of quote %d", duplicate.ExistingID)
+		p.ExistingID = &duplicate.ExistingID
+	}
+	writeProblem(w, r, p)
+}
@@ -2,32 +2,51 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
-	"math/rand"
+	"mime"
 	"net/http"
+	"net/url"
 	"quote_book/pkg/entities"
 	"quote_book/pkg/service"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func NewAddQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		logger := *logger.With("requestID", rand.Int63(), "func", "AddQuoteHandler") //better use uuid but only standart lib
+		logger := *logger.With("requestID", requestID(w, r), "func", "AddQuoteHandler")
 
 		var quote entities.Quote
 		err := json.NewDecoder(r.Body).Decode(&quote)
 		if err != nil {
 			logger.Error("JSON parsing failed", "error", err.Error())
-			jsonError(w, http.StatusBadRequest, "bad json")
+			problem(w, r, http.StatusBadRequest, "bad json")
 			return
 		}
 
-		err = qs.AddQuote(quote)
+		// ?allow_duplicate=true - добавить, даже если у автора уже есть такая цитата
+		var opts []entities.AddOption
+		if raw := r.URL.Query().Get("allow_duplicate"); raw != "" {
+			allow, err := strconv.ParseBool(raw)
+			if err != nil {
+				logger.Error("Not valid query", "error", err.Error())
+				problem(w, r, http.StatusBadRequest, "not valid allow_duplicate")
+				return
+			}
+			if allow {
+				opts = append(opts, entities.AllowDuplicate)
+			}
+		}
+
+		err = qs.AddQuote(r.Context(), quote, opts...)
 		if err != nil {
-			logger.Error("Adding quote failed", "error", err.Error())
-			jsonError(w, http.StatusInternalServerError, "quote not added")
+			serviceError(w, r, logger, err, "quote not added")
 			return
 		}
 
@@ -38,77 +57,163 @@ func NewAddQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w htt
 
 func NewGetQuotesHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		logger := *logger.With("requestID", rand.Int63(), "func", "GetQuotesHandler")
+		logger := *logger.With("requestID", requestID(w, r), "func", "GetQuotesHandler")
 
-		author := r.URL.Query().Get("author")
+		query, page, err := quoteQuery(r.URL.Query())
+		if err != nil {
+			logger.Error("Not valid query", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		quotes, err := qs.GetQuotes(r.Context(), query, page)
+		if err != nil {
+			serviceError(w, r, logger, err, "getting quotes error")
+			return
+		}
+
+		if quotes.NextCursor != "" {
+			w.Header().Set("Link", nextLink(r, quotes.NextCursor))
+		}
+
+		logger.Info("Quotes recived")
+		writeJSON(w, r, logger, quotes)
+	}
+}
 
-		quotes, err := qs.GetQuotes(author)
+func NewSearchQuotesHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "SearchQuotesHandler")
 
+		values := r.URL.Query()
+		limit, err := intParam(values, "limit")
 		if err != nil {
-			logger.Error("Getting quotes failed", "error", err.Error())
-			jsonError(w, http.StatusInternalServerError, "getting quotes error")
+			logger.Error("Not valid limit", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		jsonQuotes, err := json.Marshal(quotes)
+		results, err := qs.SearchQuotes(r.Context(), values.Get("q"), valueOr(limit, 0))
 		if err != nil {
-			logger.Error("Quotes marshaling failed", "error", err.Error())
-			jsonError(w, http.StatusInternalServerError, "quotes marshaling error")
+			serviceError(w, r, logger, err, "searching quotes error")
 			return
 		}
 
-		logger.Info("Quotes recived")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(jsonQuotes)
+		logger.Info("Quotes found", "count", len(results))
+		writeJSON(w, r, logger, struct {
+			Results []entities.SearchResult `json:"results"`
+		}{results})
 	}
 }
 
-func NewGetRandomQuotesHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+func NewGetQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		logger := *logger.With("requestID", rand.Int63(), "func", "GetRandomQuotesHandler")
+		logger := *logger.With("requestID", requestID(w, r), "func", "GetQuoteHandler")
 
-		quote, err := qs.GetRandomQuote()
+		id, err := quoteID(r)
 		if err != nil {
-			logger.Error("Quote getting failed", "error", err.Error())
-			jsonError(w, http.StatusInternalServerError, "quote getting error")
+			logger.Error("Not valid id", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, "not valid id")
+			return
 		}
 
-		jsonQuote, err := json.Marshal(quote)
+		quote, err := qs.GetQuoteByID(r.Context(), id)
 		if err != nil {
-			logger.Error("Quote marshaling failed", "error", err.Error())
-			jsonError(w, http.StatusInternalServerError, "quotes marshaling error")
+			serviceError(w, r, logger, err, "quote getting error")
 			return
 		}
 
 		logger.Info("Quote recived")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(jsonQuote)
+		writeJSON(w, r, logger, quote)
 	}
 }
 
-func NewDeleteQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+func NewUpdateQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		logger := *logger.With("requestID", rand.Int63(), "func", "DeleteQuoteHandler")
+		logger := *logger.With("requestID", requestID(w, r), "func", "UpdateQuoteHandler")
 
-		vars := mux.Vars(r)
-		rawID, ok := vars["id"]
-		if !ok {
-			logger.Error("No id for deleting", "error", "no id")
-			jsonError(w, http.StatusBadRequest, "no id in request")
+		id, err := quoteID(r)
+		if err != nil {
+			logger.Error("Not valid id", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, "not valid id")
 			return
 		}
 
-		id, err := strconv.Atoi(rawID)
+		var quote entities.Quote
+		err = json.NewDecoder(r.Body).Decode(&quote)
+		if err != nil {
+			logger.Error("JSON parsing failed", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, "bad json")
+			return
+		}
+		quote.ID = id
+
+		updated, err := qs.UpdateQuote(r.Context(), quote)
+		if err != nil {
+			serviceError(w, r, logger, err, "quote not updated")
+			return
+		}
+
+		logger.Info("Quote updated")
+		writeJSON(w, r, logger, updated)
+	}
+}
+
+func NewPatchQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "PatchQuoteHandler")
+
+		id, err := quoteID(r)
 		if err != nil {
 			logger.Error("Not valid id", "error", err.Error())
-			jsonError(w, http.StatusBadRequest, "not valid id")
+			problem(w, r, http.StatusBadRequest, "not valid id")
 			return
 		}
 
-		err = qs.DeleteQuote(id)
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != "" && contentType != "application/merge-patch+json" && contentType != "application/json" {
+			logger.Error("Unsupported content type", "contentType", contentType)
+			problem(w, r, http.StatusUnsupportedMediaType, "expected application/merge-patch+json")
+			return
+		}
+
+		// патч должен быть JSON-объектом, иначе он заменил бы цитату целиком
+		var patch map[string]json.RawMessage
+		body, err := io.ReadAll(r.Body)
+		if err == nil {
+			err = json.Unmarshal(body, &patch)
+		}
+		if err != nil || patch == nil {
+			logger.Error("JSON parsing failed", "error", fmt.Sprint(err))
+			problem(w, r, http.StatusBadRequest, "bad json")
+			return
+		}
+
+		updated, err := qs.PatchQuote(r.Context(), id, body)
 		if err != nil {
-			logger.Error("Deleting quote error", "error", err.Error())
-			jsonError(w, http.StatusInternalServerError, "deleting quote error")
+			serviceError(w, r, logger, err, "quote not updated")
+			return
+		}
+
+		logger.Info("Quote patched")
+		writeJSON(w, r, logger, updated)
+	}
+}
+
+func NewDeleteQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "DeleteQuoteHandler")
+
+		id, err := quoteID(r)
+		if err != nil {
+			logger.Error("Not valid id", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, "not valid id")
+			return
+		}
+
+		err = qs.DeleteQuote(r.Context(), id)
+		if err != nil {
+			serviceError(w, r, logger, err, "deleting quote error")
 			return
 		}
 
@@ -117,8 +222,134 @@ func NewDeleteQuoteHandler(qs service.QuoteService, logger *slog.Logger) func(w
 	}
 }
 
-func jsonError(w http.ResponseWriter, status int, message string) {
+// параметры выдачи из строки запроса; значения проверяет сервис, здесь только разбор
+func quoteQuery(values url.Values) (entities.QuoteQuery, entities.PageRequest, error) {
+	query := entities.QuoteQuery{
+		Author:  values.Get("author"),
+		Tags:    values["tag"],
+		TagMode: entities.TagMode(values.Get("tag_mode")),
+		SortBy:  entities.SortField(values.Get("sort")),
+	}
+	query.Attributions, query.ExcludeAttributions = attributions(values)
+	desc, page, err := orderAndPage(values)
+	if err != nil {
+		return query, page, err
+	}
+	query.Desc = desc
+
+	params := map[string]**int{
+		"min_id":     &query.MinID,
+		"max_id":     &query.MaxID,
+		"min_length": &query.MinLength,
+		"max_length": &query.MaxLength,
+	}
+	for name, target := range params {
+		if *target, err = intParam(values, name); err != nil {
+			return query, page, err
+		}
+	}
+	if query.CreatedAfter, err = timeParam(values, "created_after"); err != nil {
+		return query, page, err
+	}
+	if query.CreatedBefore, err = timeParam(values, "created_before"); err != nil {
+		return query, page, err
+	}
+	return query, page, nil
+}
+
+// общие для списков параметры order, limit и cursor
+func orderAndPage(values url.Values) (bool, entities.PageRequest, error) {
+	desc := false
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return false, entities.PageRequest{}, fmt.Errorf("not valid order %q", order)
+	}
+
+	page := entities.PageRequest{Cursor: values.Get("cursor")}
+	limit, err := intParam(values, "limit")
+	if err != nil {
+		return desc, page, err
+	}
+	page.Limit = valueOr(limit, 0)
+	return desc, page, nil
+}
+
+func intParam(values url.Values, name string) (*int, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("not valid %s", name)
+	}
+	return &v, nil
+}
+
+// параметр attribution можно повторять: подходит любой из статусов, кроме записанных с минусом (-disputed)
+func attributions(values url.Values) (include, exclude []entities.AttributionStatus) {
+	for _, status := range values["attribution"] {
+		if excluded, ok := strings.CutPrefix(status, "-"); ok {
+			exclude = append(exclude, entities.AttributionStatus(excluded))
+		} else {
+			include = append(include, entities.AttributionStatus(status))
+		}
+	}
+	return include, exclude
+}
+
+// время в RFC 3339 или дата ГГГГ-ММ-ДД (полночь UTC)
+func timeParam(values url.Values, name string) (*time.Time, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("not valid %s", name)
+}
+
+func valueOr(v *int, def int) int {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// ссылка на следующую страницу с теми же параметрами запроса
+func nextLink(r *http.Request, cursor string) string {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return "<" + next.String() + `>; rel="next"`
+}
+
+func quoteID(r *http.Request) (int, error) {
+	rawID, ok := mux.Vars(r)["id"]
+	if !ok {
+		return 0, errors.New("no id in request")
+	}
+
+	return strconv.Atoi(rawID)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, logger slog.Logger, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("Response marshaling failed", "error", err.Error())
+		problem(w, r, http.StatusInternalServerError, "marshaling error")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+	w.Write(data)
 }
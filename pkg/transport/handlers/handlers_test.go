@@ -2,7 +2,9 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -11,8 +13,11 @@ import (
 	"quote_book/pkg/entities"
 	"quote_book/pkg/service"
 	"quote_book/pkg/transport/handlers"
+	"slices"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -24,7 +29,10 @@ var (
 )
 
 func TestMain(m *testing.M) {
-	db := memdb.New()
+	db, err := memdb.New()
+	if err != nil {
+		panic(err)
+	}
 	svc = service.NewQuoteService(db)
 	logger = slog.Default()
 	router = setupRouter()
@@ -33,10 +41,24 @@ func TestMain(m *testing.M) {
 
 func setupRouter() *mux.Router {
 	r := mux.NewRouter()
+	r.Use(handlers.RequestIDMiddleware)
+	r.NotFoundHandler = handlers.NewNotFoundHandler(logger)
+	r.MethodNotAllowedHandler = handlers.NewMethodNotAllowedHandler(r, logger)
 	r.HandleFunc("/quotes", handlers.NewAddQuoteHandler(svc, logger)).Methods(http.MethodPost)
 	r.HandleFunc("/quotes", handlers.NewGetQuotesHandler(svc, logger)).Methods(http.MethodGet)
 	r.HandleFunc("/quotes/random", handlers.NewGetRandomQuotesHandler(svc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/quotes/daily", handlers.NewGetDailyQuoteHandler(svc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/quotes/search", handlers.NewSearchQuotesHandler(svc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/quotes/{id}", handlers.NewGetQuoteHandler(svc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/quotes/{id}", handlers.NewUpdateQuoteHandler(svc, logger)).Methods(http.MethodPut)
+	r.HandleFunc("/quotes/{id}", handlers.NewPatchQuoteHandler(svc, logger)).Methods(http.MethodPatch)
 	r.HandleFunc("/quotes/{id}", handlers.NewDeleteQuoteHandler(svc, logger)).Methods(http.MethodDelete)
+	r.HandleFunc("/quotes/{id}/tags/{tag}", handlers.NewAddTagHandler(svc, logger)).Methods(http.MethodPut)
+	r.HandleFunc("/quotes/{id}/tags/{tag}", handlers.NewRemoveTagHandler(svc, logger)).Methods(http.MethodDelete)
+	r.HandleFunc("/tags", handlers.NewGetTagsHandler(svc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/authors", handlers.NewGetAuthorsHandler(svc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/authors/suggest", handlers.NewSuggestAuthorsHandler(svc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/authors/{name}/merge", handlers.NewMergeAuthorsHandler(svc, logger)).Methods(http.MethodPost)
 	return r
 }
 
@@ -63,8 +85,9 @@ func TestAddGetDeleteQuote(t *testing.T) {
 		t.Fatalf("GetQuotes: expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
-	var quotes []entities.Quote
-	err := json.NewDecoder(w.Body).Decode(&quotes)
+	var page entities.QuotePage
+	err := json.NewDecoder(w.Body).Decode(&page)
+	quotes := page.Quotes
 	if err != nil {
 		t.Fatalf("GetQuotes: decode error: %v", err)
 	}
@@ -122,10 +145,11 @@ func TestAddGetDeleteQuote(t *testing.T) {
 		t.Fatalf("GetQuotes after delete: expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
-	err = json.NewDecoder(w.Body).Decode(&quotes)
+	err = json.NewDecoder(w.Body).Decode(&page)
 	if err != nil {
 		t.Fatalf("GetQuotes after delete: decode error: %v", err)
 	}
+	quotes = page.Quotes
 
 	for _, q := range quotes {
 		if q.ID == randomQuote.ID {
@@ -169,7 +193,7 @@ func TestDeleteQuoteNoID(t *testing.T) {
 func TestGetRandomQuote(t *testing.T) {
 	// Сначала добавим цитату, чтобы она была в базе
 	quote := entities.Quote{Text: "Random test quote", Author: "Random Tester"}
-	err := svc.AddQuote(quote)
+	err := svc.AddQuote(context.Background(), quote)
 	if err != nil {
 		t.Fatalf("failed to add quote for test: %v", err)
 	}
@@ -198,7 +222,11 @@ func TestGetRandomQuote(t *testing.T) {
 
 func TestGetRandomQuoteEmptyDB(t *testing.T) {
 	// Создаём новый сервис с пустой базой
-	db := memdb.New()
+	db, err := memdb.New()
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
 	emptySvc := service.NewQuoteService(db)
 	logger := slog.Default()
 
@@ -209,17 +237,974 @@ func TestGetRandomQuoteEmptyDB(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Fatalf("GetRandomQuote empty DB: expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GetRandomQuote empty DB: expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("GetRandomQuote empty DB: expected problem+json, got %q", ct)
 	}
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
+	var errResp handlers.Problem
+	err = json.NewDecoder(w.Body).Decode(&errResp)
 	if err != nil {
 		t.Fatalf("GetRandomQuote empty DB: decode error: %v", err)
 	}
 
-	if errMsg, ok := errResp["error"]; !ok || errMsg == "" {
-		t.Fatal("GetRandomQuote empty DB: expected error message in response")
+	if errResp.Type == "" || errResp.Detail == "" || errResp.Status != http.StatusNotFound {
+		t.Fatalf("GetRandomQuote empty DB: unexpected problem %+v", errResp)
+	}
+}
+
+func TestUpdateAndPatchQuote(t *testing.T) {
+	err := svc.AddQuote(context.Background(), entities.Quote{Text: "Tpyo quote", Author: "Editor"})
+	if err != nil {
+		t.Fatalf("failed to add quote for test: %v", err)
+	}
+	page, _ := svc.GetQuotes(context.Background(), entities.QuoteQuery{Author: "Editor"}, entities.PageRequest{})
+	quotes := page.Quotes
+	if len(quotes) != 1 {
+		t.Fatalf("expected 1 quote for test author, got %d", len(quotes))
+	}
+	id := strconv.Itoa(quotes[0].ID)
+
+	// Полная замена
+	body, _ := json.Marshal(entities.Quote{Text: "Typo quote", Author: "Editor"})
+	req := httptest.NewRequest(http.MethodPut, "/quotes/"+id, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("UpdateQuote: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var updated entities.Quote
+	_ = json.NewDecoder(w.Body).Decode(&updated)
+	if updated.Text != "Typo quote" || strconv.Itoa(updated.ID) != id {
+		t.Fatalf("UpdateQuote: unexpected quote %v", updated)
+	}
+
+	// Частичное изменение - меняется только автор
+	req = httptest.NewRequest(http.MethodPatch, "/quotes/"+id, bytes.NewReader([]byte(`{"author":"Corrected Editor"}`)))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("PatchQuote: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	_ = json.NewDecoder(w.Body).Decode(&updated)
+	if updated.Text != "Typo quote" || updated.Author != "Corrected Editor" {
+		t.Fatalf("PatchQuote: unexpected quote %v", updated)
+	}
+
+	// Патч не объектом
+	req = httptest.NewRequest(http.MethodPatch, "/quotes/"+id, bytes.NewReader([]byte(`"text"`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("PatchQuote with non-object: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetQuoteByID(t *testing.T) {
+	err := svc.AddQuote(context.Background(), entities.Quote{Text: "Permalink quote", Author: "Linker"})
+	if err != nil {
+		t.Fatalf("failed to add quote for test: %v", err)
+	}
+	page, _ := svc.GetQuotes(context.Background(), entities.QuoteQuery{Author: "Linker"}, entities.PageRequest{})
+	quotes := page.Quotes
+	if len(quotes) != 1 {
+		t.Fatalf("expected 1 quote for test author, got %d", len(quotes))
+	}
+	id := strconv.Itoa(quotes[0].ID)
+
+	req := httptest.NewRequest(http.MethodGet, "/quotes/"+id, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetQuote: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var got entities.Quote
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if got.Text != "Permalink quote" {
+		t.Fatalf("GetQuote: unexpected quote %v", got)
+	}
+
+	// Логически удаленная цитата недоступна еще до сборки мусора
+	_ = svc.DeleteQuote(context.Background(), quotes[0].ID)
+
+	req = httptest.NewRequest(http.MethodGet, "/quotes/"+id, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GetQuote deleted: expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/quotes/999999", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GetQuote unknown: expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestErrorStatuses(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"blank quote", http.MethodPost, "/quotes", `{"author":"A","quote":""}`, http.StatusBadRequest},
+		{"bad source url", http.MethodPost, "/quotes", `{"author":"A","quote":"Q","source":{"url":"example.com"}}`, http.StatusBadRequest},
+		{"bad source year", http.MethodPost, "/quotes", `{"author":"A","quote":"Q","source":{"year":"1900"}}`, http.StatusBadRequest},
+		{"bad attribution", http.MethodPost, "/quotes", `{"author":"A","quote":"Q","attribution":"fake"}`, http.StatusBadRequest},
+		{"negative weight", http.MethodPost, "/quotes", `{"author":"A","quote":"Q","weight":-1}`, http.StatusBadRequest},
+		{"too heavy", http.MethodPost, "/quotes", `{"author":"A","quote":"Q","weight":1001}`, http.StatusBadRequest},
+		{"bad attribution filter", http.MethodGet, "/quotes?attribution=fake", "", http.StatusBadRequest},
+		{"bad random attribution filter", http.MethodGet, "/quotes/random?attribution=fake", "", http.StatusBadRequest},
+		{"bad attribution exclusion", http.MethodGet, "/quotes?attribution=-fake", "", http.StatusBadRequest},
+		{"every attribution excluded", http.MethodGet, "/quotes/random?attribution=verified&attribution=-verified", "", http.StatusBadRequest},
+		{"delete unknown", http.MethodDelete, "/quotes/999999", "", http.StatusNotFound},
+		{"update unknown", http.MethodPut, "/quotes/999999", `{"author":"A","quote":"Q"}`, http.StatusNotFound},
+		{"patch unknown", http.MethodPatch, "/quotes/999999", `{"author":"A"}`, http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, bytes.NewReader([]byte(c.body)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, w.Code)
+		}
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		status      int
+		problemType string
+	}{
+		{"blank quote", http.MethodPost, "/quotes", `{"author":"A","quote":""}`, http.StatusBadRequest, "/problems/validation"},
+		{"bad json", http.MethodPost, "/quotes", `{bad`, http.StatusBadRequest, "/problems/bad-request"},
+		{"unknown quote", http.MethodGet, "/quotes/999999", "", http.StatusNotFound, "/problems/not-found"},
+		{"unknown route", http.MethodGet, "/nowhere", "", http.StatusNotFound, "/problems/not-found"},
+		{"wrong method", http.MethodPost, "/quotes/1", "", http.StatusMethodNotAllowed, "/problems/method-not-allowed"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, bytes.NewReader([]byte(c.body)))
+		req.Header.Set("X-Request-ID", "test-"+c.name)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, w.Code)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%s: expected problem+json, got %q", c.name, ct)
+			continue
+		}
+
+		var p handlers.Problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Errorf("%s: decode error: %v", c.name, err)
+			continue
+		}
+		if p.Type != c.problemType || p.Status != c.status || p.Title == "" || p.Instance != c.path {
+			t.Errorf("%s: unexpected problem %+v", c.name, p)
+		}
+		if p.RequestID != "test-"+c.name {
+			t.Errorf("%s: expected request id %q, got %q", c.name, "test-"+c.name, p.RequestID)
+		}
+		if p.RequestID != w.Header().Get("X-Request-ID") {
+			t.Errorf("%s: request id in body %q does not match header %q", c.name, p.RequestID, w.Header().Get("X-Request-ID"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/quotes/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if allow := w.Header().Get("Allow"); allow != "GET, PUT, PATCH, DELETE" {
+		t.Errorf("wrong method: unexpected Allow header %q", allow)
+	}
+}
+
+func TestGetQuotesPagination(t *testing.T) {
+	db, err := memdb.New()
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
+	pagedSvc := service.NewQuoteService(db)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/quotes", handlers.NewGetQuotesHandler(pagedSvc, logger)).Methods(http.MethodGet)
+
+	for i := 0; i < 5; i++ {
+		_ = pagedSvc.AddQuote(context.Background(), entities.Quote{Text: "Q" + strconv.Itoa(i), Author: "Pager"})
+	}
+	_ = pagedSvc.DeleteQuote(context.Background(), 2)
+
+	// Обходим все страницы по next_cursor: ID по возрастанию, без удаленной цитаты
+	var ids []int
+	path := "/quotes?author=Pager&limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages > 5 {
+			t.Fatal("GetQuotes: pagination does not terminate")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("GetQuotes page: expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var page entities.QuotePage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("GetQuotes page: decode error: %v", err)
+		}
+		for _, q := range page.Quotes {
+			ids = append(ids, q.ID)
+		}
+
+		path = ""
+		if page.NextCursor != "" {
+			link := w.Header().Get("Link")
+			if !strings.Contains(link, `rel="next"`) || !strings.Contains(link, "author=Pager") {
+				t.Fatalf("GetQuotes page: unexpected Link header %q", link)
+			}
+			path = "/quotes?author=Pager&limit=2&cursor=" + page.NextCursor
+		}
+	}
+
+	if fmt.Sprint(ids) != "[0 1 3 4]" {
+		t.Fatalf("GetQuotes pages: expected ids [0 1 3 4], got %v", ids)
+	}
+
+	// Сортировка по длине текста по убыванию с фильтром по длине и ID
+	_ = pagedSvc.AddQuote(context.Background(), entities.Quote{Text: "Длинная цитата", Author: "Sorter"})
+	_ = pagedSvc.AddQuote(context.Background(), entities.Quote{Text: "Короче", Author: "Sorter"})
+	_ = pagedSvc.AddQuote(context.Background(), entities.Quote{Text: "Самая длинная цитата", Author: "Sorter"})
+
+	req := httptest.NewRequest(http.MethodGet, "/quotes?sort=length&order=desc&min_length=5&min_id=5", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var page entities.QuotePage
+	_ = json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Quotes) != 3 || page.Quotes[0].ID != 7 || page.Quotes[1].ID != 5 || page.Quotes[2].ID != 6 {
+		t.Fatalf("GetQuotes sorted by length: unexpected %v", page.Quotes)
+	}
+
+	// Курсор от другого порядка сортировки не принимается
+	req = httptest.NewRequest(http.MethodGet, "/quotes?sort=length&limit=1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	_ = json.NewDecoder(w.Body).Decode(&page)
+
+	req = httptest.NewRequest(http.MethodGet, "/quotes?sort=author&cursor="+page.NextCursor, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GetQuotes with foreign cursor: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	for _, bad := range []string{
+		"/quotes?limit=0x", "/quotes?limit=100000", "/quotes?cursor=!!!",
+		"/quotes?sort=rating", "/quotes?order=up", "/quotes?min_length=x", "/quotes?min_id=5&max_id=1",
+	} {
+		req := httptest.NewRequest(http.MethodGet, bad, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GetQuotes %s: expected status %d, got %d", bad, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestSearchQuotes(t *testing.T) {
+	err := svc.AddQuote(context.Background(), entities.Quote{Text: "Searchable unicorn quote", Author: "Finder"})
+	if err != nil {
+		t.Fatalf("failed to add quote for test: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/quotes/search?q=unicorn", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("SearchQuotes: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp struct {
+		Results []entities.SearchResult `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("SearchQuotes: decode error: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Quote.Author != "Finder" || resp.Results[0].Score <= 0 {
+		t.Fatalf("SearchQuotes: unexpected results %v", resp.Results)
+	}
+
+	for _, bad := range []string{"/quotes/search", "/quotes/search?q=unicorn&limit=1000"} {
+		req = httptest.NewRequest(http.MethodGet, bad, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("SearchQuotes %s: expected status %d, got %d", bad, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestSuggestAuthors(t *testing.T) {
+	ctx := context.Background()
+	_ = svc.AddQuote(ctx, entities.Quote{Text: "Suggest one", Author: "Zorro Suggestov"})
+	_ = svc.AddQuote(ctx, entities.Quote{Text: "Suggest two", Author: "zorro  suggestov"})
+	_ = svc.AddQuote(ctx, entities.Quote{Text: "Suggest three", Author: "Zorba Greek"})
+
+	req := httptest.NewRequest(http.MethodGet, "/authors/suggest?prefix=zor", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("SuggestAuthors: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp struct {
+		Authors []entities.AuthorCount `json:"authors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("SuggestAuthors: decode error: %v", err)
+	}
+	want := []entities.AuthorCount{{Author: "Zorro Suggestov", Quotes: 2}, {Author: "Zorba Greek", Quotes: 1}}
+	if fmt.Sprint(resp.Authors) != fmt.Sprint(want) {
+		t.Fatalf("SuggestAuthors: expected %v, got %v", want, resp.Authors)
+	}
+
+	for _, bad := range []string{"/authors/suggest", "/authors/suggest?prefix=zor&limit=1000"} {
+		req = httptest.NewRequest(http.MethodGet, bad, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("SuggestAuthors %s: expected status %d, got %d", bad, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestMergeAuthors(t *testing.T) {
+	ctx := context.Background()
+	_ = svc.AddQuote(ctx, entities.Quote{Text: "Merge one", Author: "Merge Source"})
+	_ = svc.AddQuote(ctx, entities.Quote{Text: "Merge two", Author: "Merge Target"})
+
+	req := httptest.NewRequest(http.MethodPost, "/authors/merge%20source/merge", strings.NewReader(`{"into":"merge target"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("MergeAuthors: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var author entities.AuthorCount
+	if err := json.NewDecoder(w.Body).Decode(&author); err != nil {
+		t.Fatalf("MergeAuthors: decode error: %v", err)
+	}
+	if author != (entities.AuthorCount{Author: "Merge Target", Quotes: 2}) {
+		t.Fatalf("MergeAuthors: unexpected result %v", author)
+	}
+
+	// Поиск по старому имени находит цитаты нового автора
+	req = httptest.NewRequest(http.MethodGet, "/quotes?author=Merge%20Source", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var page entities.QuotePage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("GetQuotes: decode error: %v", err)
+	}
+	if len(page.Quotes) != 2 || page.Quotes[0].Author != "Merge Target" {
+		t.Fatalf("GetQuotes by alias: unexpected quotes %v", page.Quotes)
+	}
+
+	bad := []struct {
+		path, body string
+	}{
+		{"/authors/merge%20target/merge", `{"into":"Merge Target"}`},
+		{"/authors/someone/merge", `{"into":""}`},
+		{"/authors/someone/merge", `{bad`},
+	}
+	for _, c := range bad {
+		req = httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("MergeAuthors %s %s: expected status %d, got %d", c.path, c.body, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/authors/Nobody/merge", strings.NewReader(`{"into":"Nobody2"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("MergeAuthors unknown author: expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetAuthors(t *testing.T) {
+	db, err := memdb.New()
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
+	authorSvc := service.NewQuoteService(db)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/authors", handlers.NewGetAuthorsHandler(authorSvc, logger)).Methods(http.MethodGet)
+
+	ctx := context.Background()
+	for i, author := range []string{"bacon", "Acton", "Confucius", "Bacon", "Confucius", "Confucius", "Dante"} {
+		_ = authorSvc.AddQuote(ctx, entities.Quote{Text: "Q" + strconv.Itoa(i), Author: author})
+	}
+	_ = authorSvc.DeleteQuote(ctx, 6)
+
+	// Обходим все страницы по next_cursor: по числу цитат по убыванию, удаленные не считаются
+	var got []string
+	path := "/authors?sort=count&order=desc&limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("GetAuthors: pagination does not terminate")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("GetAuthors page: expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var page entities.AuthorPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("GetAuthors page: decode error: %v", err)
+		}
+		for _, a := range page.Authors {
+			got = append(got, fmt.Sprintf("%s:%d", a.Author, a.Quotes))
+		}
+
+		path = ""
+		if page.NextCursor != "" {
+			path = "/authors?sort=count&order=desc&limit=2&cursor=" + page.NextCursor
+		}
+	}
+	if fmt.Sprint(got) != "[Confucius:3 bacon:2 Acton:1]" {
+		t.Fatalf("GetAuthors pages: unexpected %v", got)
+	}
+
+	// По умолчанию - по имени без учета регистра
+	req := httptest.NewRequest(http.MethodGet, "/authors", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var page entities.AuthorPage
+	_ = json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Authors) != 3 || page.Authors[0].Author != "Acton" || page.Authors[1].Author != "bacon" {
+		t.Fatalf("GetAuthors by name: unexpected %v", page.Authors)
+	}
+
+	for _, bad := range []string{"/authors?sort=length", "/authors?order=up", "/authors?limit=-1", "/authors?cursor=!!!"} {
+		req := httptest.NewRequest(http.MethodGet, bad, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GetAuthors %s: expected status %d, got %d", bad, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestQuoteTags(t *testing.T) {
+	db, err := memdb.New()
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
+	tagSvc := service.NewQuoteService(db)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/quotes", handlers.NewGetQuotesHandler(tagSvc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/quotes/{id}/tags/{tag}", handlers.NewAddTagHandler(tagSvc, logger)).Methods(http.MethodPut)
+	r.HandleFunc("/quotes/{id}/tags/{tag}", handlers.NewRemoveTagHandler(tagSvc, logger)).Methods(http.MethodDelete)
+	r.HandleFunc("/tags", handlers.NewGetTagsHandler(tagSvc, logger)).Methods(http.MethodGet)
+
+	ctx := context.Background()
+	_ = tagSvc.AddQuote(ctx, entities.Quote{Text: "Q0", Author: "A", Tags: []string{"love"}})
+	_ = tagSvc.AddQuote(ctx, entities.Quote{Text: "Q1", Author: "A"})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPut, "/quotes/1/tags/Work")
+	var quote entities.Quote
+	_ = json.NewDecoder(w.Body).Decode(&quote)
+	if w.Code != http.StatusOK || fmt.Sprint(quote.Tags) != "[work]" {
+		t.Fatalf("AddTag: unexpected status %d, tags %v", w.Code, quote.Tags)
+	}
+	_ = serve(http.MethodPut, "/quotes/1/tags/love")
+	_ = serve(http.MethodPut, "/quotes/0/tags/work")
+	_ = serve(http.MethodDelete, "/quotes/0/tags/love")
+
+	cases := map[string]string{
+		"/quotes?tag=work&tag=love":              "[1]",
+		"/quotes?tag=work&tag=love&tag_mode=any": "[0 1]",
+		"/quotes?tag=war":                        "[]",
+	}
+	for path, want := range cases {
+		w = serve(http.MethodGet, path)
+		var page entities.QuotePage
+		_ = json.NewDecoder(w.Body).Decode(&page)
+
+		ids := []int{}
+		for _, q := range page.Quotes {
+			ids = append(ids, q.ID)
+		}
+		if fmt.Sprint(ids) != want {
+			t.Errorf("GetQuotes %s: expected %s, got %v", path, want, ids)
+		}
+	}
+
+	w = serve(http.MethodGet, "/tags")
+	var resp struct {
+		Tags []entities.TagCount `json:"tags"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if fmt.Sprint(resp.Tags) != "[{work 2} {love 1}]" {
+		t.Fatalf("GetTags: unexpected %v", resp.Tags)
+	}
+
+	bad := map[string]int{
+		"/quotes?tag=a&tag_mode=some": http.StatusBadRequest,
+		"/quotes/7/tags/work":         http.StatusNotFound,
+		"/quotes/x/tags/work":         http.StatusBadRequest,
+	}
+	for path, status := range bad {
+		method := http.MethodGet
+		if strings.Contains(path, "/tags/") {
+			method = http.MethodPut
+		}
+		if w = serve(method, path); w.Code != status {
+			t.Errorf("%s %s: expected status %d, got %d", method, path, status, w.Code)
+		}
+	}
+}
+
+func TestGetQuotesCreatedRange(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	db, err := memdb.New(memdb.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
+	timedSvc := service.NewQuoteService(db)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/quotes", handlers.NewGetQuotesHandler(timedSvc, logger)).Methods(http.MethodGet)
+
+	// по дню на цитату, а у последней время раньше, чем у предыдущей
+	for i, hours := range []int{0, 24, 48, 30} {
+		now = time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC).Add(time.Duration(hours) * time.Hour)
+		_ = timedSvc.AddQuote(context.Background(), entities.Quote{Text: "Q" + strconv.Itoa(i), Author: "Timer"})
+	}
+
+	var raw map[string][]map[string]any
+	req := httptest.NewRequest(http.MethodGet, "/quotes?limit=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	_ = json.NewDecoder(w.Body).Decode(&raw)
+	if got := raw["quotes"][0]["created_at"]; got != "2024-03-01T23:00:00Z" {
+		t.Fatalf("GetQuotes: unexpected created_at %v", got)
+	}
+
+	cases := map[string]string{
+		"/quotes?created_after=2024-03-02":                               "[1 2 3]",
+		"/quotes?created_before=2024-03-03T00:00:00Z":                    "[0 1]",
+		"/quotes?created_after=2024-03-02&created_before=2024-03-03":     "[1]",
+		"/quotes?created_after=2024-03-03T01:00:00%2B03:00&sort=created": "[1 3 2]",
+	}
+	for path, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var page entities.QuotePage
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		ids := []int{}
+		for _, q := range page.Quotes {
+			ids = append(ids, q.ID)
+		}
+		if fmt.Sprint(ids) != want {
+			t.Errorf("GetQuotes %s: expected %s, got %v", path, want, ids)
+		}
+	}
+
+	// по страницам в порядке created_at, а не ID
+	var ids []int
+	path := "/quotes?sort=created&order=desc&limit=1"
+	for pages := 0; path != ""; pages++ {
+		if pages > 4 {
+			t.Fatal("GetQuotes: pagination does not terminate")
+		}
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var page entities.QuotePage
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		for _, q := range page.Quotes {
+			ids = append(ids, q.ID)
+		}
+		path = ""
+		if page.NextCursor != "" {
+			path = "/quotes?sort=created&order=desc&limit=1&cursor=" + page.NextCursor
+		}
+	}
+	if fmt.Sprint(ids) != "[2 3 1 0]" {
+		t.Fatalf("GetQuotes by created desc: expected [2 3 1 0], got %v", ids)
+	}
+
+	for _, bad := range []string{
+		"/quotes?created_after=yesterday", "/quotes?created_before=2024-13-01",
+		"/quotes?created_after=2024-03-03&created_before=2024-03-02",
+	} {
+		req := httptest.NewRequest(http.MethodGet, bad, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GetQuotes %s: expected status %d, got %d", bad, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestQuoteSource(t *testing.T) {
+	db, err := memdb.New()
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
+	sourceSvc := service.NewQuoteService(db)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/quotes", handlers.NewAddQuoteHandler(sourceSvc, logger)).Methods(http.MethodPost)
+	r.HandleFunc("/quotes", handlers.NewGetQuotesHandler(sourceSvc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/quotes/random", handlers.NewGetRandomQuotesHandler(sourceSvc, logger)).Methods(http.MethodGet)
+	r.HandleFunc("/quotes/{id}", handlers.NewGetQuoteHandler(sourceSvc, logger)).Methods(http.MethodGet)
+
+	for _, body := range []string{
+		`{"author":"Confucius","quote":"Q0","source":{"title":"Analects","year":-475,"page":"2.17","url":"https://example.com/analects"},"attribution":"verified"}`,
+		`{"author":"Einstein","quote":"Q1","attribution":"misattributed"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/quotes", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("AddQuote %s: expected status %d, got %d", body, http.StatusCreated, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/quotes/0", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var quote entities.Quote
+	_ = json.NewDecoder(w.Body).Decode(&quote)
+	want := entities.Source{Title: "Analects", Year: -475, Page: "2.17", URL: "https://example.com/analects"}
+	if quote.Source == nil || *quote.Source != want || quote.Attribution != entities.AttributionVerified {
+		t.Fatalf("GetQuote: unexpected source %v, attribution %q", quote.Source, quote.Attribution)
+	}
+
+	// спорные и чужие цитаты скрыты из случайной выдачи - перечислением подходящих или исключением остальных
+	for _, filter := range []string{"attribution=verified&attribution=unverified", "attribution=-disputed&attribution=-misattributed"} {
+		for i := 0; i < 20; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/quotes/random?"+filter, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			var random entities.Quote
+			_ = json.NewDecoder(w.Body).Decode(&random)
+			if w.Code != http.StatusOK || random.ID != 0 {
+				t.Fatalf("GetRandomQuote %s: expected quote 0, got status %d, quote %d", filter, w.Code, random.ID)
+			}
+		}
+	}
+
+	for filter, want := range map[string]string{
+		"attribution=-misattributed":                      "[0]",
+		"attribution=-verified":                           "[1]",
+		"attribution=verified&attribution=-disputed":      "[0]",
+		"attribution=misattributed&attribution=-verified": "[1]",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/quotes?"+filter, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var page entities.QuotePage
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		ids := make([]int, 0, len(page.Quotes))
+		for _, quote := range page.Quotes {
+			ids = append(ids, quote.ID)
+		}
+		if w.Code != http.StatusOK || fmt.Sprint(ids) != want {
+			t.Fatalf("GetQuotes %s: expected %s, got status %d, quotes %v", filter, want, w.Code, ids)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/quotes/random?attribution=disputed", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GetRandomQuote with no matching quotes: expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetRandomQuoteFiltered(t *testing.T) {
+	db, err := memdb.New()
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
+	randomSvc := service.NewQuoteService(db)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/quotes/random", handlers.NewGetRandomQuotesHandler(randomSvc, logger)).Methods(http.MethodGet)
+
+	_ = randomSvc.AddQuote(context.Background(), entities.Quote{Text: "Я помню чудное мгновенье", Author: "Пушкин", Tags: []string{"love"}})
+	_ = randomSvc.AddQuote(context.Background(), entities.Quote{Text: "Мороз и солнце", Author: "Пушкин", Tags: []string{"winter"}})
+	_ = randomSvc.AddQuote(context.Background(), entities.Quote{Text: "Все смешалось в доме", Author: "Толстой", Tags: []string{"love"}})
+
+	cases := map[string]int{
+		"/quotes/random?author=пушкин&max_length=15": 1,
+		"/quotes/random?tag=love&author=Толстой":     2,
+		"/quotes/random?tag=love&max_length=22":      2,
+	}
+	for path, want := range cases {
+		for i := 0; i < 10; i++ {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			var quote entities.Quote
+			_ = json.NewDecoder(w.Body).Decode(&quote)
+			if w.Code != http.StatusOK || quote.ID != want {
+				t.Fatalf("GetRandomQuote %s: expected quote %d, got status %d, quote %d", path, want, w.Code, quote.ID)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/quotes/random?count=5", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp struct {
+		Quotes []entities.Quote `json:"quotes"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	ids := make([]int, 0, len(resp.Quotes))
+	for _, q := range resp.Quotes {
+		ids = append(ids, q.ID)
+	}
+	slices.Sort(ids)
+	if w.Code != http.StatusOK || fmt.Sprint(ids) != "[0 1 2]" {
+		t.Fatalf("GetRandomQuote count=5: expected all 3 quotes, got status %d, ids %v", w.Code, ids)
+	}
+
+	// один и тот же seed - одни и те же цитаты
+	var seeded []string
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/quotes/random?seed=tweet-42&count=2", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		seeded = append(seeded, w.Body.String())
+	}
+	if seeded[0] != seeded[1] || seeded[1] != seeded[2] {
+		t.Fatalf("GetRandomQuote with seed: different responses %v", seeded)
+	}
+
+	for _, mode := range []string{"uniform", "author", "weighted"} {
+		req := httptest.NewRequest(http.MethodGet, "/quotes/random?count=3&mode="+mode, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Quotes []entities.Quote `json:"quotes"`
+		}
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusOK || len(resp.Quotes) != 3 {
+			t.Fatalf("GetRandomQuote mode=%s: unexpected status %d, quotes %v", mode, w.Code, resp.Quotes)
+		}
+	}
+
+	// с bag цитаты не повторяются, пока клиент не увидит все
+	bagged := make(map[int]bool)
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/quotes/random?bag=widget-7", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var quote entities.Quote
+		_ = json.NewDecoder(w.Body).Decode(&quote)
+		bagged[quote.ID] = true
+	}
+	if len(bagged) != 3 {
+		t.Fatalf("GetRandomQuote with bag: expected 3 different quotes, got %v", bagged)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/quotes/random?count=2&author=Лермонтов", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if body := strings.TrimSpace(w.Body.String()); w.Code != http.StatusOK || body != `{"quotes":[]}` {
+		t.Fatalf("GetRandomQuote count without matches: unexpected status %d, body %s", w.Code, body)
+	}
+
+	bad := map[string]int{
+		"/quotes/random?count=0":                         http.StatusBadRequest,
+		"/quotes/random?count=101":                       http.StatusBadRequest,
+		"/quotes/random?count=two":                       http.StatusBadRequest,
+		"/quotes/random?max_length=short":                http.StatusBadRequest,
+		"/quotes/random?bag=a&seed=b":                    http.StatusBadRequest,
+		"/quotes/random?mode=fair":                       http.StatusBadRequest,
+		"/quotes/random?mode=weighted&bag=a":             http.StatusBadRequest,
+		"/quotes/random?mode=author&author=Лермонтов":    http.StatusNotFound,
+		"/quotes/random?bag=" + strings.Repeat("x", 129): http.StatusBadRequest,
+		"/quotes/random?author=Лермонтов":                http.StatusNotFound,
+		"/quotes/random?tag=winter&max_length=10":        http.StatusNotFound,
+	}
+	for path, status := range bad {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("GetRandomQuote %s: expected status %d, got %d", path, status, w.Code)
+		}
+	}
+}
+
+func TestGetDailyQuote(t *testing.T) {
+	db, err := memdb.New()
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
+	// 22:30 UTC - уже следующий день в часовом поясе сервиса
+	now := time.Date(2024, 3, 1, 22, 30, 0, 0, time.UTC)
+	dailySvc := service.NewQuoteService(db,
+		service.WithLocation(time.FixedZone("MSK", 3*60*60)),
+		service.WithClock(func() time.Time { return now }),
+	)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/quotes/daily", handlers.NewGetDailyQuoteHandler(dailySvc, logger)).Methods(http.MethodGet)
+	get := func(path string) (int, entities.DailyQuote) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var quote entities.DailyQuote
+		_ = json.NewDecoder(w.Body).Decode(&quote)
+		return w.Code, quote
+	}
+
+	if status, _ := get("/quotes/daily"); status != http.StatusNotFound {
+		t.Fatalf("GetDailyQuote on empty DB: expected status %d, got %d", http.StatusNotFound, status)
+	}
+	_ = dailySvc.AddQuote(context.Background(), entities.Quote{Text: "Q0", Author: "A"})
+	_ = dailySvc.AddQuote(context.Background(), entities.Quote{Text: "Q1", Author: "A"})
+
+	status, first := get("/quotes/daily")
+	if status != http.StatusOK || first.Date != "2024-03-02" || first.Text == "" {
+		t.Fatalf("GetDailyQuote: unexpected status %d, quote %+v", status, first)
+	}
+	for _, path := range []string{"/quotes/daily", "/quotes/daily?date=2024-03-02"} {
+		if status, quote := get(path); status != http.StatusOK || quote.ID != first.ID {
+			t.Fatalf("GetDailyQuote %s: expected quote %d, got status %d, quote %d", path, first.ID, status, quote.ID)
+		}
+	}
+
+	// на следующий день - другая цитата, вчерашняя доступна по дате
+	now = now.AddDate(0, 0, 1)
+	status, second := get("/quotes/daily")
+	if status != http.StatusOK || second.Date != "2024-03-03" || second.ID == first.ID {
+		t.Fatalf("GetDailyQuote next day: expected another quote, got status %d, quote %+v", status, second)
+	}
+	if status, quote := get("/quotes/daily?date=2024-03-02"); status != http.StatusOK || quote.ID != first.ID || quote.Date != "2024-03-02" {
+		t.Fatalf("GetDailyQuote of the previous day: expected quote %d, got status %d, quote %+v", first.ID, status, quote)
+	}
+
+	bad := map[string]int{
+		"/quotes/daily?date=2024-03-01": http.StatusNotFound,
+		"/quotes/daily?date=2024-03-04": http.StatusBadRequest,
+		"/quotes/daily?date=03.03.2024": http.StatusBadRequest,
+		"/quotes/daily?date=2024-02-30": http.StatusBadRequest,
+	}
+	for path, want := range bad {
+		if status, _ := get(path); status != want {
+			t.Errorf("GetDailyQuote %s: expected status %d, got %d", path, want, status)
+		}
+	}
+}
+
+func TestAddDuplicateQuote(t *testing.T) {
+	db, err := memdb.New()
+	if err != nil {
+		t.Fatalf("memdb.New failed: %v", err)
+	}
+	defer db.Close()
+	dupSvc := service.NewQuoteService(db)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/quotes", handlers.NewAddQuoteHandler(dupSvc, logger)).Methods(http.MethodPost)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("/quotes", `{"author":"Confucius","quote":"Life is really simple."}`); w.Code != http.StatusCreated {
+		t.Fatalf("AddQuote: expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	w := post("/quotes", `{"author":"confucius","quote":"“Life is REALLY simple”"}`)
+	var p handlers.Problem
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	if w.Code != http.StatusConflict || p.ExistingID == nil || *p.ExistingID != 0 || p.Type != "/problems/conflict" {
+		t.Fatalf("AddQuote duplicate: unexpected status %d, problem %+v", w.Code, p)
+	}
+
+	if w := post("/quotes?allow_duplicate=true", `{"author":"Confucius","quote":"Life is really simple!"}`); w.Code != http.StatusCreated {
+		t.Fatalf("AddQuote with allow_duplicate: expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	if w := post("/quotes?allow_duplicate=false", `{"author":"Confucius","quote":"Life is really simple"}`); w.Code != http.StatusConflict {
+		t.Fatalf("AddQuote with allow_duplicate=false: expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if w := post("/quotes?allow_duplicate=maybe", `{"author":"Confucius","quote":"Another"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("AddQuote with bad allow_duplicate: expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 }
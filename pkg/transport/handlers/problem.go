@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const problemContentType = "application/problem+json"
+
+// типы ошибок - относительные URI, по ним клиенты различают ошибки вместо разбора текста
+const (
+	problemBadRequest       = "/problems/bad-request"
+	problemValidation       = "/problems/validation"
+	problemNotFound         = "/problems/not-found"
+	problemMethodNotAllowed = "/problems/method-not-allowed"
+	problemConflict         = "/problems/conflict"
+	problemUnsupportedMedia = "/problems/unsupported-media-type"
+	problemUnavailable      = "/problems/unavailable"
+	problemInternal         = "/problems/internal"
+)
+
+var problemTypes = map[int]string{
+	http.StatusBadRequest:           problemBadRequest,
+	http.StatusNotFound:             problemNotFound,
+	http.StatusMethodNotAllowed:     problemMethodNotAllowed,
+	http.StatusConflict:             problemConflict,
+	http.StatusUnsupportedMediaType: problemUnsupportedMedia,
+	http.StatusServiceUnavailable:   problemUnavailable,
+	http.StatusInternalServerError:  problemInternal,
+}
+
+var problemTitles = map[string]string{
+	problemValidation: "Validation failed",
+}
+
+// документ ошибки по RFC 7807
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// расширение для дублей: ID уже существующей цитаты
+	ExistingID *int `json:"existing_id,omitempty"`
+}
+
+// ошибка с типом, выбранным по статусу
+func problem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblem(w, r, Problem{Type: problemTypes[status], Status: status, Detail: detail})
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = problemTitles[p.Type]
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.RequestURI()
+	p.RequestID = requestID(w, r)
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+func NewNotFoundHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "NotFoundHandler")
+
+		logger.Info("Route not found", "method", r.Method, "path", r.URL.Path)
+		problem(w, r, http.StatusNotFound, "no such route")
+	}
+}
+
+// router нужен, чтобы перечислить разрешенные методы в заголовке Allow
+func NewMethodNotAllowedHandler(router *mux.Router, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "MethodNotAllowedHandler")
+
+		if allowed := allowedMethods(router, r); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+		}
+
+		logger.Info("Method not allowed", "method", r.Method, "path", r.URL.Path)
+		problem(w, r, http.StatusMethodNotAllowed, "method "+r.Method+" is not allowed for this resource")
+	}
+}
+
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	methods := []string{
+		http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+	}
+
+	allowed := make([]string, 0, len(methods))
+	for _, method := range methods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+
+		var match mux.RouteMatch
+		if router.Match(probe, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"quote_book/pkg/entities"
+	"quote_book/pkg/service"
+)
+
+// без count отдает одну цитату, с count - {"quotes": [...]} из count разных цитат
+func NewGetRandomQuotesHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "GetRandomQuotesHandler")
+
+		values := r.URL.Query()
+		query, err := randomQuery(values)
+		if err != nil {
+			logger.Error("Not valid query", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		count, err := intParam(values, "count")
+		if err != nil {
+			logger.Error("Not valid count", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if count == nil {
+			quote, err := qs.GetRandomQuote(r.Context(), query)
+			if err != nil {
+				serviceError(w, r, logger, err, "quote getting error")
+				return
+			}
+
+			logger.Info("Quote recived")
+			writeJSON(w, r, logger, quote)
+			return
+		}
+
+		quotes, err := qs.GetRandomQuotes(r.Context(), query, *count)
+		if err != nil {
+			serviceError(w, r, logger, err, "quotes getting error")
+			return
+		}
+
+		logger.Info("Quotes recived", "count", len(quotes))
+		writeJSON(w, r, logger, struct {
+			Quotes []entities.Quote `json:"quotes"`
+		}{quotes})
+	}
+}
+
+// фильтры случайной цитаты: те же параметры, что у списка, теги только все сразу
+func randomQuery(values url.Values) (entities.RandomQuery, error) {
+	query := entities.RandomQuery{
+		Author: values.Get("author"),
+		Tags:   values["tag"],
+		Seed:   values.Get("seed"),
+		Bag:    values.Get("bag"),
+		Mode:   entities.RandomMode(values.Get("mode")),
+	}
+	query.Attributions, query.ExcludeAttributions = attributions(values)
+	var err error
+	query.MaxLength, err = intParam(values, "max_length")
+	return query, err
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// чужие идентификаторы длиннее этого не принимаем
+const maxRequestIDLen = 64
+
+type ctxKey int
+
+const requestIDKey ctxKey = iota
+
+// берет ID из заголовка запроса или выдает новый и кладет его в контекст и заголовок ответа
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := incomingRequestID(r)
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// mux не вызывает middleware для ненайденных маршрутов, поэтому ID может понадобиться выдать здесь;
+// ответ запоминает выданный ID, чтобы лог и тело ошибки совпадали
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		return id
+	}
+
+	id := RequestIDFromContext(r.Context())
+	if id == "" {
+		id = incomingRequestID(r)
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
+
+func incomingRequestID(r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" || len(id) > maxRequestIDLen {
+		return newRequestID()
+	}
+	return id
+}
+
+func newRequestID() string {
+	return strconv.FormatInt(rand.Int63(), 16) //better use uuid but only standart lib
+}
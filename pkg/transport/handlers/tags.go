@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"quote_book/pkg/entities"
+	"quote_book/pkg/service"
+
+	"github.com/gorilla/mux"
+)
+
+func NewGetTagsHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "GetTagsHandler")
+
+		tags, err := qs.GetTags(r.Context())
+		if err != nil {
+			serviceError(w, r, logger, err, "getting tags error")
+			return
+		}
+
+		logger.Info("Tags recived", "count", len(tags))
+		writeJSON(w, r, logger, struct {
+			Tags []entities.TagCount `json:"tags"`
+		}{tags})
+	}
+}
+
+// PUT /quotes/{id}/tags/{tag}
+func NewAddTagHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "AddTagHandler")
+
+		id, err := quoteID(r)
+		if err != nil {
+			logger.Error("Not valid id", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, "not valid id")
+			return
+		}
+
+		quote, err := qs.AddTag(r.Context(), id, mux.Vars(r)["tag"])
+		if err != nil {
+			serviceError(w, r, logger, err, "tag not added")
+			return
+		}
+
+		logger.Info("Tag added")
+		writeJSON(w, r, logger, quote)
+	}
+}
+
+// DELETE /quotes/{id}/tags/{tag}
+func NewRemoveTagHandler(qs service.QuoteService, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *logger.With("requestID", requestID(w, r), "func", "RemoveTagHandler")
+
+		id, err := quoteID(r)
+		if err != nil {
+			logger.Error("Not valid id", "error", err.Error())
+			problem(w, r, http.StatusBadRequest, "not valid id")
+			return
+		}
+
+		quote, err := qs.RemoveTag(r.Context(), id, mux.Vars(r)["tag"])
+		if err != nil {
+			serviceError(w, r, logger, err, "tag not removed")
+			return
+		}
+
+		logger.Info("Tag removed")
+		writeJSON(w, r, logger, quote)
+	}
+}
@@ -19,3 +19,11 @@ func (g *IDGenerator) GetID() int {
 	g.nextID++
 	return id
 }
+
+// следующий ID, который будет выдан, без его резервирования
+func (g *IDGenerator) NextID() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.nextID
+}
@@ -0,0 +1,42 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"quote_book/pkg/utils"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	cases := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, c := range cases {
+		got, err := utils.MergePatch([]byte(c.doc), []byte(c.patch))
+		if err != nil {
+			t.Fatalf("MergePatch(%s, %s) failed: %v", c.doc, c.patch, err)
+		}
+
+		var gotV, wantV any
+		_ = json.Unmarshal(got, &gotV)
+		_ = json.Unmarshal([]byte(c.want), &wantV)
+		if !reflect.DeepEqual(gotV, wantV) {
+			t.Errorf("MergePatch(%s, %s) = %s; want %s", c.doc, c.patch, got, c.want)
+		}
+	}
+}
+
+func TestMergePatchBadJSON(t *testing.T) {
+	if _, err := utils.MergePatch([]byte(`{}`), []byte(`{bad`)); err == nil {
+		t.Fatal("MergePatch with bad patch should return error")
+	}
+}